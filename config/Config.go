@@ -0,0 +1,372 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// Privileges are earned with reputation, see Config.Privileges
+const (
+	PrivilegeComment   = "comment"
+	PrivilegeDownvote  = "downvote"
+	PrivilegeReview    = "review"
+	PrivilegeEdit      = "edit"
+	PrivilegeCloseVote = "close-vote"
+)
+
+// Privileges lists every privilege, from the one earned first
+var Privileges = []string{PrivilegeComment, PrivilegeDownvote, PrivilegeReview, PrivilegeEdit, PrivilegeCloseVote}
+
+// Config holds every setting of the server binary.
+// Values are read from the YAML config file, then the QAENGINE_* environment variables,
+// then the command line flags, each overriding the previous one
+type Config struct {
+	// Address the HTTP server listens on, host:port
+	Address string `yaml:"address"`
+	// Storage backend, mongo or memory
+	Store    string `yaml:"store"`
+	MongoURI string `yaml:"mongouri"`
+	Database string `yaml:"database"`
+
+	// JSON file listing the JWT signing keys, see tokens.KeysConfig
+	KeysFile string `yaml:"keysfile"`
+	// HS256 secret used when no keys file is configured
+	TokenSecret          string        `yaml:"tokensecret"`
+	AccessTokenLifetime  time.Duration `yaml:"accesstokenlifetime"`
+	RefreshTokenLifetime time.Duration `yaml:"refreshtokenlifetime"`
+
+	BcryptCost int `yaml:"bcryptcost"`
+
+	// Usernames allowed to moderate, such as approving tag synonyms
+	Moderators []string `yaml:"moderators"`
+
+	// Similarity, between 0 and 1, above which a new question is taken for a duplicate of an existing one
+	DuplicateThreshold float64 `yaml:"duplicatethreshold"`
+	// What happens to such a question, warn adds it with the list of duplicates and reject refuses it
+	DuplicateAction string `yaml:"duplicateaction"`
+
+	// How long after posting a comment its author can still edit it, 0 to never allow it
+	CommentEditWindow time.Duration `yaml:"commenteditwindow"`
+
+	// Most reputation a user can earn from upvotes in a day, accepted answers are not capped
+	ReputationDailyCap int `yaml:"reputationdailycap"`
+	// Reputation needed for each privilege. Commenting on your own posts and editing them needs no privilege,
+	// and moderators hold every privilege
+	Privileges map[string]int `yaml:"privileges"`
+	// Number of close votes that close a question
+	CloseVotesNeeded int `yaml:"closevotesneeded"`
+}
+
+// Default returns the configuration used when nothing else is set
+func Default() Config {
+	return Config{
+		Address:              ":5000",
+		Store:                "mongo",
+		MongoURI:             "mongodb://localhost:27017",
+		Database:             "QAEngine",
+		AccessTokenLifetime:  20 * time.Minute,
+		RefreshTokenLifetime: 7 * 24 * time.Hour,
+		BcryptCost:           bcrypt.DefaultCost,
+		DuplicateThreshold:   0.8,
+		DuplicateAction:      "warn",
+		CommentEditWindow:    5 * time.Minute,
+		ReputationDailyCap:   200,
+		Privileges: map[string]int{
+			PrivilegeComment:   50,
+			PrivilegeDownvote:  125,
+			PrivilegeReview:    500,
+			PrivilegeEdit:      2000,
+			PrivilegeCloseVote: 3000,
+		},
+		CloseVotesNeeded: 3,
+	}
+}
+
+// Load builds the configuration from the config file, the environment and the given command line arguments
+// and validates it. The config file is named by the -config flag or the QAENGINE_CONFIG variable
+func Load(args []string) (*Config, error) {
+	config := Default()
+
+	flags := flag.NewFlagSet("QAEngine", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("QAENGINE_CONFIG"), "YAML config file")
+	address := flags.String("address", "", "address to listen on")
+	storeBackend := flags.String("store", "", "storage backend to use (mongo or memory)")
+	mongoURI := flags.String("mongo-uri", "", "MongoDB connection string")
+	database := flags.String("database", "", "MongoDB database name")
+	keysFile := flags.String("keys", "", "JSON file listing the JWT signing keys")
+	accessTokenLifetime := flags.Duration("access-token-lifetime", 0, "lifetime of the access tokens")
+	refreshTokenLifetime := flags.Duration("refresh-token-lifetime", 0, "lifetime of the refresh tokens")
+	bcryptCost := flags.Int("bcrypt-cost", 0, "bcrypt cost of the password hashes")
+	moderators := flags.String("moderators", "", "comma separated usernames of the moderators")
+	duplicateThreshold := flags.Float64("duplicate-threshold", 0, "similarity above which a new question is a duplicate")
+	duplicateAction := flags.String("duplicate-action", "", "what to do with duplicate questions (warn or reject)")
+	commentEditWindow := flags.Duration("comment-edit-window", 0, "how long after posting a comment it can be edited")
+	reputationDailyCap := flags.Int("reputation-daily-cap", 0, "most reputation earned from upvotes in a day")
+	privileges := flags.String("privileges", "", "comma separated privilege=reputation thresholds, such as comment=50")
+	closeVotesNeeded := flags.Int("close-votes", 0, "number of close votes that close a question")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// 1. Config file
+	if *configFile != "" {
+		data, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("Parsing %s: %v", *configFile, err)
+		}
+	}
+
+	// 2. Environment
+	if err := config.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	// 3. Flags, only the ones given on the command line
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "address":
+			config.Address = *address
+		case "store":
+			config.Store = *storeBackend
+		case "mongo-uri":
+			config.MongoURI = *mongoURI
+		case "database":
+			config.Database = *database
+		case "keys":
+			config.KeysFile = *keysFile
+		case "access-token-lifetime":
+			config.AccessTokenLifetime = *accessTokenLifetime
+		case "refresh-token-lifetime":
+			config.RefreshTokenLifetime = *refreshTokenLifetime
+		case "bcrypt-cost":
+			config.BcryptCost = *bcryptCost
+		case "moderators":
+			config.Moderators = splitList(*moderators)
+		case "duplicate-threshold":
+			config.DuplicateThreshold = *duplicateThreshold
+		case "duplicate-action":
+			config.DuplicateAction = *duplicateAction
+		case "comment-edit-window":
+			config.CommentEditWindow = *commentEditWindow
+		case "reputation-daily-cap":
+			config.ReputationDailyCap = *reputationDailyCap
+		case "close-votes":
+			config.CloseVotesNeeded = *closeVotesNeeded
+		}
+	})
+	if *privileges != "" {
+		if err := config.setPrivileges(*privileges); err != nil {
+			return nil, fmt.Errorf("-privileges: %v", err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (config *Config) loadEnv() error {
+	stringFields := map[string]*string{
+		"QAENGINE_ADDRESS":          &config.Address,
+		"QAENGINE_STORE":            &config.Store,
+		"QAENGINE_MONGO_URI":        &config.MongoURI,
+		"QAENGINE_DATABASE":         &config.Database,
+		"QAENGINE_KEYS_FILE":        &config.KeysFile,
+		"QAENGINE_TOKEN_SECRET":     &config.TokenSecret,
+		"QAENGINE_DUPLICATE_ACTION": &config.DuplicateAction,
+	}
+	for name, field := range stringFields {
+		if value, present := os.LookupEnv(name); present {
+			*field = value
+		}
+	}
+
+	durationFields := map[string]*time.Duration{
+		"QAENGINE_ACCESS_TOKEN_LIFETIME":  &config.AccessTokenLifetime,
+		"QAENGINE_REFRESH_TOKEN_LIFETIME": &config.RefreshTokenLifetime,
+		"QAENGINE_COMMENT_EDIT_WINDOW":    &config.CommentEditWindow,
+	}
+	for name, field := range durationFields {
+		if value, present := os.LookupEnv(name); present {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			*field = duration
+		}
+	}
+
+	if value, present := os.LookupEnv("QAENGINE_BCRYPT_COST"); present {
+		cost, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("QAENGINE_BCRYPT_COST: %v", err)
+		}
+		config.BcryptCost = cost
+	}
+
+	if value, present := os.LookupEnv("QAENGINE_REPUTATION_DAILY_CAP"); present {
+		reputationCap, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("QAENGINE_REPUTATION_DAILY_CAP: %v", err)
+		}
+		config.ReputationDailyCap = reputationCap
+	}
+
+	if value, present := os.LookupEnv("QAENGINE_CLOSE_VOTES"); present {
+		closeVotes, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("QAENGINE_CLOSE_VOTES: %v", err)
+		}
+		config.CloseVotesNeeded = closeVotes
+	}
+
+	if value, present := os.LookupEnv("QAENGINE_PRIVILEGES"); present {
+		if err := config.setPrivileges(value); err != nil {
+			return fmt.Errorf("QAENGINE_PRIVILEGES: %v", err)
+		}
+	}
+
+	if value, present := os.LookupEnv("QAENGINE_DUPLICATE_THRESHOLD"); present {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("QAENGINE_DUPLICATE_THRESHOLD: %v", err)
+		}
+		config.DuplicateThreshold = threshold
+	}
+
+	if value, present := os.LookupEnv("QAENGINE_MODERATORS"); present {
+		config.Moderators = splitList(value)
+	}
+	return nil
+}
+
+// Splits a comma separated list, dropping the empty entries
+func splitList(value string) []string {
+	list := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// Sets the thresholds of a comma separated list of privilege=reputation pairs, leaving the other privileges as they are
+func (config *Config) setPrivileges(value string) error {
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Expected privilege=reputation, got %q", entry)
+		}
+		threshold, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return fmt.Errorf("Invalid reputation for %s: %v", parts[0], err)
+		}
+		if config.Privileges == nil {
+			config.Privileges = map[string]int{}
+		}
+		config.Privileges[strings.TrimSpace(parts[0])] = threshold
+	}
+	return nil
+}
+
+// HasPrivilege reports whether a user with the given reputation holds the privilege
+func (config *Config) HasPrivilege(username string, reputation int, privilege string) bool {
+	return config.IsModerator(username) || reputation >= config.Privileges[privilege]
+}
+
+// IsModerator reports whether the user is one of the configured moderators
+func (config *Config) IsModerator(username string) bool {
+	for _, moderator := range config.Moderators {
+		if moderator == username {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports the first setting that cannot be used to start the server
+func (config *Config) Validate() error {
+	if _, _, err := net.SplitHostPort(config.Address); err != nil {
+		return fmt.Errorf("Invalid address %q: %v", config.Address, err)
+	}
+
+	switch config.Store {
+	case "memory":
+	case "mongo":
+		if config.MongoURI == "" {
+			return errors.New("The mongo store needs a mongouri")
+		}
+		if config.Database == "" {
+			return errors.New("The mongo store needs a database")
+		}
+	default:
+		return fmt.Errorf("Unknown store backend %q", config.Store)
+	}
+
+	if config.TokenSecret != "" && len(config.TokenSecret) < 32 {
+		return errors.New("The token secret must be at least 32 bytes long")
+	}
+	if config.AccessTokenLifetime <= 0 {
+		return errors.New("The access token lifetime must be positive")
+	}
+	if config.RefreshTokenLifetime <= config.AccessTokenLifetime {
+		return errors.New("The refresh token lifetime must be longer than the access token lifetime")
+	}
+	if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("The bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if config.DuplicateThreshold <= 0 || config.DuplicateThreshold > 1 {
+		return errors.New("The duplicate threshold must be above 0 and at most 1")
+	}
+	if config.DuplicateAction != "warn" && config.DuplicateAction != "reject" {
+		return fmt.Errorf("Unknown duplicate action %q, use warn or reject", config.DuplicateAction)
+	}
+	if config.CommentEditWindow < 0 {
+		return errors.New("The comment edit window cannot be negative")
+	}
+	if config.ReputationDailyCap <= 0 {
+		return errors.New("The reputation daily cap must be positive")
+	}
+	for name, threshold := range config.Privileges {
+		if !isPrivilege(name) {
+			return fmt.Errorf("Unknown privilege %q, use one of %s", name, strings.Join(Privileges, ", "))
+		}
+		if threshold < 0 {
+			return fmt.Errorf("The reputation needed for %s cannot be negative", name)
+		}
+	}
+	for _, name := range Privileges {
+		if _, present := config.Privileges[name]; !present {
+			return fmt.Errorf("The reputation needed for %s is missing", name)
+		}
+	}
+	if config.CloseVotesNeeded < 1 {
+		return errors.New("At least one close vote must be needed to close a question")
+	}
+	return nil
+}
+
+func isPrivilege(name string) bool {
+	for _, privilege := range Privileges {
+		if privilege == name {
+			return true
+		}
+	}
+	return false
+}
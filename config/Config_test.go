@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Writes the YAML config file and returns its path
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func load(t *testing.T, args ...string) *Config {
+	t.Helper()
+
+	config, err := Load(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestDefaults(t *testing.T) {
+	config := load(t)
+	defaults := Default()
+	if config.Address != defaults.Address || config.Store != defaults.Store || config.CloseVotesNeeded != defaults.CloseVotesNeeded {
+		t.Errorf("Load() = %+v, want the defaults", config)
+	}
+}
+
+// Flags override the environment, which overrides the file, which overrides the defaults
+func TestPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+address: ":6000"
+database: fromfile
+bcryptcost: 11
+accesstokenlifetime: 30m
+`)
+
+	config := load(t, "-config", path)
+	if config.Address != ":6000" || config.Database != "fromfile" || config.BcryptCost != 11 || config.AccessTokenLifetime != 30*time.Minute {
+		t.Errorf("file values not applied: %+v", config)
+	}
+	if config.MongoURI != Default().MongoURI {
+		t.Errorf("MongoURI = %q, want the default", config.MongoURI)
+	}
+
+	t.Setenv("QAENGINE_ADDRESS", ":7000")
+	t.Setenv("QAENGINE_BCRYPT_COST", "12")
+	t.Setenv("QAENGINE_ACCESS_TOKEN_LIFETIME", "1h")
+	config = load(t, "-config", path)
+	if config.Address != ":7000" || config.BcryptCost != 12 || config.AccessTokenLifetime != time.Hour {
+		t.Errorf("environment does not override the file: %+v", config)
+	}
+	if config.Database != "fromfile" {
+		t.Errorf("Database = %q, the file value should stay", config.Database)
+	}
+
+	config = load(t, "-config", path, "-address", ":8000", "-access-token-lifetime", "2h")
+	if config.Address != ":8000" || config.AccessTokenLifetime != 2*time.Hour {
+		t.Errorf("flags do not override the environment: %+v", config)
+	}
+	if config.BcryptCost != 12 {
+		t.Errorf("BcryptCost = %d, the environment value should stay when no flag is given", config.BcryptCost)
+	}
+}
+
+// The config file can also come from the environment
+func TestConfigFileFromEnvironment(t *testing.T) {
+	t.Setenv("QAENGINE_CONFIG", writeConfig(t, `database: fromenvfile`))
+	if config := load(t); config.Database != "fromenvfile" {
+		t.Errorf("Database = %q, want fromenvfile", config.Database)
+	}
+}
+
+func TestInvalidValues(t *testing.T) {
+	invalid := map[string][]string{
+		"unknown store":          {"-store", "sqlite"},
+		"bcrypt cost too high":   {"-bcrypt-cost", "99"},
+		"refresh outlived":       {"-refresh-token-lifetime", "1m"},
+		"address without a port": {"-address", "localhost"},
+	}
+	for name, args := range invalid {
+		if _, err := Load(args); err == nil {
+			t.Errorf("%s: %v accepted", name, args)
+		}
+	}
+
+	t.Setenv("QAENGINE_REFRESH_TOKEN_LIFETIME", "a week")
+	if _, err := Load(nil); err == nil {
+		t.Error("invalid duration in the environment accepted")
+	}
+}
+
+// Privilege thresholds are merged one by one, each source only replacing the ones it names
+func TestPrivilegePrecedence(t *testing.T) {
+	path := writeConfig(t, `
+privileges:
+  comment: 10
+  edit: 100
+`)
+	t.Setenv("QAENGINE_PRIVILEGES", "comment=20,downvote=30")
+
+	config := load(t, "-config", path, "-privileges", "comment=40")
+	want := map[string]int{
+		PrivilegeComment:   40,
+		PrivilegeDownvote:  30,
+		PrivilegeEdit:      100,
+		PrivilegeReview:    Default().Privileges[PrivilegeReview],
+		PrivilegeCloseVote: Default().Privileges[PrivilegeCloseVote],
+	}
+	for privilege, reputation := range want {
+		if config.Privileges[privilege] != reputation {
+			t.Errorf("%s needs %d, want %d", privilege, config.Privileges[privilege], reputation)
+		}
+	}
+}
+
+func TestHasPrivilege(t *testing.T) {
+	config := Default()
+	config.Moderators = []string{"mod"}
+
+	if config.HasPrivilege("user", 49, PrivilegeComment) {
+		t.Error("49 reputation holds the comment privilege")
+	}
+	if !config.HasPrivilege("user", 50, PrivilegeComment) {
+		t.Error("50 reputation lacks the comment privilege")
+	}
+	if !config.HasPrivilege("mod", 0, PrivilegeCloseVote) {
+		t.Error("moderator lacks a privilege")
+	}
+}
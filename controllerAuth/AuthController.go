@@ -6,12 +6,11 @@ import (
 	"errors"
 	"net/http"
 	// "os"
-	"time"
 
+	"example.org/config"
 	"example.org/model"
-	"github.com/dgrijalva/jwt-go"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"example.org/store"
+	"example.org/tokens"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,7 +21,7 @@ type Result struct {
 }
 
 
-func UserRegisterController(response http.ResponseWriter, request *http.Request, QAEngineDatabase *mongo.Database)  {
+func UserRegisterController(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineConfig *config.Config)  {
 	response.Header().Add("Content-Type", "application/json")
 	
 	user := model.UserModel{}
@@ -37,8 +36,8 @@ func UserRegisterController(response http.ResponseWriter, request *http.Request,
 	}
 
 	// Before generating hash check if the username and email combination is found in the database
-	err1 := checkEmailInDatabase(QAEngineDatabase, user.Email)
-	err2 := checkUsernameInDatabase(QAEngineDatabase, user.Username)
+	err1 := checkEmailInDatabase(QAEngineStore, user.Email)
+	err2 := checkUsernameInDatabase(QAEngineStore, user.Username)
 
 	if err1 == nil {
 		// User with that email found
@@ -60,15 +59,34 @@ func UserRegisterController(response http.ResponseWriter, request *http.Request,
 		return
 	}
 	var hash string
-	hash, err = generateHashPassword(user.Password,&user)
+	hash, err = generateHashPassword(user.Password, QAEngineConfig.BcryptCost)
 
-	if err != nil {
-		// Handle the error to the response of the request
+	if err == bcrypt.ErrPasswordTooLong {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{
+			Err : true,
+			Message : "Password is too long",
+		})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{
+			Err : true,
+			Message : "Failed to hash the password",
+		})
+		return
 	}
 	// else Add the new user to the database
 
-	_, err = addUserToDatabase(&user, hash, QAEngineDatabase)
-	if err != nil {
+	err = addUserToDatabase(&user, hash, QAEngineStore)
+	if err == store.ErrDuplicate {
+		// Someone else signed up with the username or email in the meantime
+		response.WriteHeader(http.StatusConflict)
+		json.NewEncoder(response).Encode(Result{
+			Err : true,
+			Message : "Username or email already taken",
+		})
+	} else if err != nil {
 		response.WriteHeader(http.StatusInternalServerError)
 		responseResult := Result{
 			Err : true,
@@ -87,13 +105,11 @@ func UserRegisterController(response http.ResponseWriter, request *http.Request,
 	defer request.Body.Close()
 }
 
-func UserLoginController(response http.ResponseWriter, request *http.Request, QAEngineDatabase *mongo.Database)  {
+func UserLoginController(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config)  {
 	// 1. Get the login credentials from the request body
 	// 2. Check if the given email or username is there in the database or not
 	// 3. Check the given password against the one in the database
-	
-	jwtKey := []byte("my_secret_key")
-	// secret := "suifhapqihopakn;OKR01"
+
 	// Step 1
 	var loginCreds model.UserLogin
 
@@ -102,10 +118,10 @@ func UserLoginController(response http.ResponseWriter, request *http.Request, QA
 	// Step 2.
 	if loginCreds.Username == "" {
 		// Only email was provided
-		err = checkEmailInDatabase(QAEngineDatabase, loginCreds.Email)
+		err = checkEmailInDatabase(QAEngineStore, loginCreds.Email)
 	} else if loginCreds.Email == "" {
 		// Only Username was provided
-		err = checkUsernameInDatabase(QAEngineDatabase, loginCreds.Username)
+		err = checkUsernameInDatabase(QAEngineStore, loginCreds.Username)
 	} 
 
 	if err != nil {
@@ -121,7 +137,7 @@ func UserLoginController(response http.ResponseWriter, request *http.Request, QA
 	} else {
 		// User name and email found in the database, Safe to login the user
 		// Step 3 Check the given password in the database
-		username, email, err := comparePassword(QAEngineDatabase, &loginCreds)
+		username, email, err := comparePassword(QAEngineStore, &loginCreds)
 
 		if err != nil {
 			// Error comparing or finding the user
@@ -132,19 +148,8 @@ func UserLoginController(response http.ResponseWriter, request *http.Request, QA
 			})
 			return
 		} else {
-			// Password valid
-			expirationTime := time.Now().Add(20 * time.Minute)
-			claims := &model.Claims{
-				Username:  username,
-				Email: email,
-				StandardClaims: jwt.StandardClaims{
-					ExpiresAt : expirationTime.Unix(),
-				},
-			}
-
-			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-			tokenString, err := token.SignedString(jwtKey)
+			// Password valid, start a new session
+			err = startSession(response, QAEngineStore, QAEngineKeys, QAEngineConfig, username, email, "")
 
 			if err != nil {
 				
@@ -155,12 +160,6 @@ func UserLoginController(response http.ResponseWriter, request *http.Request, QA
 				})
 				return
 			} else {
-				
-				http.SetCookie(response, &http.Cookie{
-					Name : "token",
-					Value : tokenString,
-					Expires : expirationTime,
-				})
 				response.WriteHeader(http.StatusOK)
 				json.NewEncoder(response).Encode(Result{
 					Err : false,
@@ -175,43 +174,43 @@ func UserLoginController(response http.ResponseWriter, request *http.Request, QA
 }
 
 
-func generateHashPassword(password string, user *model.UserModel) (string, error){
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.MinCost)
+func generateHashPassword(password string, cost int) (string, error){
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 
 	if err != nil {
-		return "", errors.New("Failed to hash the password") 
+		return "", err
 	}
 	return string(hash), nil
 }
 
-func addUserToDatabase(user *model.UserModel, hashedPass string, QAEngineDatabase *mongo.Database) (*mongo.InsertOneResult, error) {
+func addUserToDatabase(user *model.UserModel, hashedPass string, QAEngineStore *store.Store) error {
 	// Check if the given username and email is already there in the database or not
-	count, err := QAEngineDatabase.Collection("users").CountDocuments(context.TODO(), bson.M{"email" : user.Email, "username" : user.Username})
-	if count > 0 {
+	_, err := QAEngineStore.Users.FindByUsernameAndEmail(context.TODO(), user.Username, user.Email)
+	if err == nil {
 		// User already exists
-		return nil, errors.New("User already exists in the database")
+		return errors.New("User already exists in the database")
 	}
 
-	if err != nil {
-		return nil, errors.New("Internal Server Error")
+	if err != store.ErrNotFound {
+		return errors.New("Internal Server Error")
 	}
 
 	user.Password = hashedPass
 	// User not found , insert a new user in the database
-	result, err := QAEngineDatabase.Collection("users").InsertOne(context.TODO(), user)
-	if err != nil {
-		return nil, errors.New("Internal Server Error")
+	err = QAEngineStore.Users.Insert(context.TODO(), user)
+	if err == store.ErrDuplicate {
+		return err
+	} else if err != nil {
+		return errors.New("Internal Server Error")
 	} else {
-		return result, nil
+		return nil
 	}
 }
 
-func checkEmailInDatabase(QAEngineDatabase *mongo.Database, email string) error {
-	result := QAEngineDatabase.Collection("users").FindOne(context.TODO(), bson.M{
-		"email" : email,
-	})
+func checkEmailInDatabase(QAEngineStore *store.Store, email string) error {
+	_, err := QAEngineStore.Users.FindByEmail(context.TODO(), email)
 
-	if result.Err() == mongo.ErrNoDocuments {
+	if err == store.ErrNotFound {
 		// No Documents with that email found in the database
 		return errors.New("No documents with that email found in the database")
 	} else {
@@ -220,12 +219,10 @@ func checkEmailInDatabase(QAEngineDatabase *mongo.Database, email string) error
 	}
 }
 
-func checkUsernameInDatabase(QAEngineDatabase *mongo.Database, username string) error {
-	result := QAEngineDatabase.Collection("users").FindOne(context.TODO(), bson.M{
-		"username" : username,
-	})
+func checkUsernameInDatabase(QAEngineStore *store.Store, username string) error {
+	_, err := QAEngineStore.Users.FindByUsername(context.TODO(), username)
 
-	if result.Err() == mongo.ErrNoDocuments {
+	if err == store.ErrNotFound {
 		// No Documents with that email found in the database
 		return errors.New("No documents with that username found in the database")
 	} else {
@@ -234,27 +231,24 @@ func checkUsernameInDatabase(QAEngineDatabase *mongo.Database, username string)
 	}
 }
 
-func comparePassword(QAEngineDatabase *mongo.Database, loginCreds *model.UserLogin) (string, string, error) {
+func comparePassword(QAEngineStore *store.Store, loginCreds *model.UserLogin) (string, string, error) {
 	// Find the user with the given credentials
 	var user model.UserReturnModel
-	var result *mongo.SingleResult
+	var err error
 	if loginCreds.Email == "" {
-		result = QAEngineDatabase.Collection("users").FindOne(context.TODO(), bson.M{
-			"username" : loginCreds.Username,
-		})
+		user, err = QAEngineStore.Users.FindByUsername(context.TODO(), loginCreds.Username)
 	} else {
-		result = QAEngineDatabase.Collection("users").FindOne(context.TODO(), bson.M{
-			"email" : loginCreds.Email,
-		})
+		user, err = QAEngineStore.Users.FindByEmail(context.TODO(), loginCreds.Email)
 	}
 	
 
-	if result.Err() == mongo.ErrNoDocuments {
+	if err == store.ErrNotFound {
 		// No documents found with that credentials
 		return "", "", errors.New("Invalid credentials")
+	} else if err != nil {
+		return "", "", errors.New("Internal Server Error")
 	} else {
-		result.Decode(&user)
-		err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginCreds.Password))
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginCreds.Password))
 
 		if err != nil {
 			// Password incorrect
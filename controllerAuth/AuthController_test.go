@@ -0,0 +1,24 @@
+package controllerAuth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Passwords bcrypt cannot hash are refused with a 400 and leave no user behind
+func TestRegisterPasswordTooLong(t *testing.T) {
+	s := newSession(t)
+	credentials := `{"username":"alice","password":"` + strings.Repeat("p", 73) + `","email":"alice@example.org"}`
+	if response := s.register(credentials); response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("register with a 73 byte password: %s, want 400", response.Status)
+	}
+
+	credentials = `{"username":"alice","password":"` + strings.Repeat("p", 72) + `","email":"alice@example.org"}`
+	if response := s.register(credentials); response.StatusCode != http.StatusOK {
+		t.Fatalf("register with a 72 byte password: %s", response.Status)
+	}
+	if response := s.login(credentials); response.StatusCode != http.StatusOK {
+		t.Errorf("login: %s", response.Status)
+	}
+}
@@ -0,0 +1,179 @@
+package controllerAuth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// The refresh token is only ever sent to the /user routes that need it
+const refreshTokenCookiePath = "/user"
+
+func randomToken(size int) (string, error) {
+	buffer := make([]byte, size)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// Refresh tokens are stored hashed so a leaked database cannot be used to resume sessions
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issues a new access token and a new refresh token and sets both as cookies.
+// An empty family starts a new session, otherwise the refresh token continues the given one
+func startSession(response http.ResponseWriter, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config, username string, email string, family string) error {
+	now := time.Now()
+	jti, err := randomToken(16)
+	if err != nil {
+		return err
+	}
+
+	accessExpiry := now.Add(QAEngineConfig.AccessTokenLifetime)
+	tokenString, err := QAEngineKeys.Sign(&model.Claims{
+		Username: username,
+		Email:    email,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: accessExpiry.Unix(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if family == "" {
+		family, err = randomToken(16)
+		if err != nil {
+			return err
+		}
+	}
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	refreshExpiry := now.Add(QAEngineConfig.RefreshTokenLifetime)
+	err = QAEngineStore.Tokens.InsertRefreshToken(context.TODO(), &model.RefreshToken{
+		ID:        hashToken(refreshToken),
+		Family:    family,
+		Username:  username,
+		Email:     email,
+		ExpiresAt: refreshExpiry,
+	})
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(response, &http.Cookie{
+		Name:    "token",
+		Value:   tokenString,
+		Path:    "/",
+		Expires: accessExpiry,
+	})
+	http.SetCookie(response, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Path:     refreshTokenCookiePath,
+		Expires:  refreshExpiry,
+		HttpOnly: true,
+	})
+	return nil
+}
+
+func clearSessionCookies(response http.ResponseWriter) {
+	http.SetCookie(response, &http.Cookie{Name: "token", Path: "/", MaxAge: -1})
+	http.SetCookie(response, &http.Cookie{Name: "refresh_token", Path: refreshTokenCookiePath, MaxAge: -1, HttpOnly: true})
+}
+
+// Exchanges a refresh token for a new access token and a new refresh token.
+// Refresh tokens are single use, presenting one a second time ends the whole session
+func RefreshTokenController(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	c, err := request.Cookie("refresh_token")
+	if err != nil {
+		response.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Refresh token not present"})
+		return
+	}
+
+	refreshToken, err := QAEngineStore.Tokens.ConsumeRefreshToken(context.TODO(), hashToken(c.Value))
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Invalid refresh token"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Internal Server Error"})
+		return
+	}
+
+	if refreshToken.Used {
+		// The token was redeemed before, someone else may hold a copy of it
+		QAEngineStore.Tokens.RevokeRefreshFamily(context.TODO(), refreshToken.Family)
+		clearSessionCookies(response)
+		response.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Refresh token already used, please login again"})
+		return
+	}
+
+	if time.Now().After(refreshToken.ExpiresAt) {
+		response.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Refresh token expired, please login again"})
+		return
+	}
+
+	err = startSession(response, QAEngineStore, QAEngineKeys, QAEngineConfig, refreshToken.Username, refreshToken.Email, refreshToken.Family)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Error sigining token"})
+		return
+	}
+
+	json.NewEncoder(response).Encode(Result{Err: false, Message: "Token refreshed"})
+}
+
+// Revokes the access token of the request and every refresh token of its session
+func LogoutController(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	err = QAEngineStore.Tokens.Revoke(context.TODO(), claims.Id, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Failed to revoke the token"})
+		return
+	}
+
+	if c, err := request.Cookie("refresh_token"); err == nil {
+		refreshToken, err := QAEngineStore.Tokens.ConsumeRefreshToken(context.TODO(), hashToken(c.Value))
+		if err == nil {
+			QAEngineStore.Tokens.RevokeRefreshFamily(context.TODO(), refreshToken.Family)
+		}
+	}
+
+	clearSessionCookies(response)
+	json.NewEncoder(response).Encode(Result{Err: false, Message: "Logged out"})
+}
@@ -0,0 +1,173 @@
+package controllerAuth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/store"
+	"example.org/tokens"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type session struct {
+	store  *store.Store
+	keys   *tokens.KeyManager
+	config *config.Config
+}
+
+func newSession(t *testing.T) *session {
+	t.Helper()
+
+	QAEngineConfig := config.Default()
+	QAEngineConfig.BcryptCost = bcrypt.MinCost
+	QAEngineKeys, err := tokens.NewRandomKeyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &session{store: store.NewMemoryStore(), keys: QAEngineKeys, config: &QAEngineConfig}
+}
+
+// Calls the handler with the body and cookies, returning the recorded response
+func (s *session) call(handler func(http.ResponseWriter, *http.Request), body string, cookies ...*http.Cookie) *http.Response {
+	request := httptest.NewRequest("POST", "/user", strings.NewReader(body))
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	return recorder.Result()
+}
+
+func (s *session) register(body string) *http.Response {
+	return s.call(func(response http.ResponseWriter, request *http.Request) {
+		UserRegisterController(response, request, s.store, s.config)
+	}, body)
+}
+
+func (s *session) login(body string) *http.Response {
+	return s.call(func(response http.ResponseWriter, request *http.Request) {
+		UserLoginController(response, request, s.store, s.keys, s.config)
+	}, body)
+}
+
+func (s *session) refresh(refreshToken *http.Cookie) *http.Response {
+	return s.call(func(response http.ResponseWriter, request *http.Request) {
+		RefreshTokenController(response, request, s.store, s.keys, s.config)
+	}, "", refreshToken)
+}
+
+func (s *session) logout(cookies ...*http.Cookie) *http.Response {
+	return s.call(func(response http.ResponseWriter, request *http.Request) {
+		LogoutController(response, request, s.store, s.keys)
+	}, "", cookies...)
+}
+
+// Reports whether the access token still verifies
+func (s *session) verifies(token *http.Cookie) bool {
+	request := httptest.NewRequest("GET", "/", nil)
+	request.AddCookie(token)
+	_, err := middlewares.VerifyRequest(httptest.NewRecorder(), request, s.store, s.keys)
+	return err == nil
+}
+
+func cookie(t *testing.T, response *http.Response, name string) *http.Cookie {
+	t.Helper()
+
+	for _, c := range response.Cookies() {
+		if c.Name == name && c.Value != "" {
+			return c
+		}
+	}
+	t.Fatalf("no %s cookie in the response", name)
+	return nil
+}
+
+// Logs in a new user and returns the access and refresh token cookies
+func (s *session) start(t *testing.T) (*http.Cookie, *http.Cookie) {
+	t.Helper()
+
+	credentials := `{"username":"alice","password":"password","email":"alice@example.org"}`
+	if response := s.register(credentials); response.StatusCode != http.StatusOK {
+		t.Fatalf("register: %s", response.Status)
+	}
+	response := s.login(credentials)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("login: %s", response.Status)
+	}
+	return cookie(t, response, "token"), cookie(t, response, "refresh_token")
+}
+
+// Every refresh hands out a new refresh token and the one presented cannot be used again
+func TestRefreshRotation(t *testing.T) {
+	s := newSession(t)
+	access, first := s.start(t)
+	if !s.verifies(access) {
+		t.Fatal("access token of the login does not verify")
+	}
+
+	response := s.refresh(first)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("refresh: %s", response.Status)
+	}
+	second := cookie(t, response, "refresh_token")
+	if second.Value == first.Value {
+		t.Error("refresh returned the same refresh token")
+	}
+	if !s.verifies(cookie(t, response, "token")) {
+		t.Error("refreshed access token does not verify")
+	}
+
+	response = s.refresh(second)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("refresh with the rotated token: %s", response.Status)
+	}
+
+	if response = s.refresh(&http.Cookie{Name: "refresh_token", Value: "unknown"}); response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unknown refresh token: %s, want 401", response.Status)
+	}
+}
+
+// Presenting a refresh token twice revokes every refresh token of its session
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	s := newSession(t)
+	_, first := s.start(t)
+
+	response := s.refresh(first)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("refresh: %s", response.Status)
+	}
+	second := cookie(t, response, "refresh_token")
+
+	if response = s.refresh(first); response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token: %s, want 401", response.Status)
+	}
+	if response = s.refresh(second); response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("refresh token of a revoked family: %s, want 401", response.Status)
+	}
+
+	// Other sessions of the same user are left alone
+	credentials := `{"username":"alice","password":"password","email":"alice@example.org"}`
+	other := cookie(t, s.login(credentials), "refresh_token")
+	if response = s.refresh(other); response.StatusCode != http.StatusOK {
+		t.Errorf("refresh in another session: %s, want 200", response.Status)
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	s := newSession(t)
+	access, refreshToken := s.start(t)
+
+	if response := s.logout(access, refreshToken); response.StatusCode != http.StatusOK {
+		t.Fatalf("logout: %s", response.Status)
+	}
+	if s.verifies(access) {
+		t.Error("access token verifies after logout")
+	}
+	if response := s.refresh(refreshToken); response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: %s, want 401", response.Status)
+	}
+}
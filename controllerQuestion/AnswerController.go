@@ -0,0 +1,208 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AnswerVoteRequest struct {
+	VoteType string `json:"votetype"`
+}
+
+// Moves the accepted answer to the front, the rest keep the order they were posted in
+func pinSelectedAnswer(question *model.Question) {
+	for i, answer := range question.Answers {
+		if answer.ISSelected {
+			copy(question.Answers[1:i+1], question.Answers[:i])
+			question.Answers[0] = answer
+			return
+		}
+	}
+}
+
+// Prepares a question for its payload. Deleted answers are left out unless showDeleted, which is
+// only set for moderators, and the accepted answer is moved to the front
+func presentQuestion(question *model.Question, showDeleted bool) {
+	if !showDeleted {
+		answers := []model.Answer{}
+		for _, answer := range question.Answers {
+			if !answer.Deleted {
+				answers = append(answers, answer)
+			}
+		}
+		question.Answers = answers
+	}
+	pinSelectedAnswer(question)
+}
+
+func presentQuestions(questions []model.Question) {
+	for i := range questions {
+		presentQuestion(&questions[i], false)
+	}
+}
+
+// Accepts the answer in the path, replacing the previously accepted one if there was any
+func AcceptAnswer(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	setSelectedAnswer(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig, true)
+}
+
+// Removes the accepted mark from the answer in the path
+func UnacceptAnswer(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	setSelectedAnswer(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig, false)
+}
+
+// The author of the accepted answer earns reputation for it, and loses it again when the answer is no longer accepted
+func setSelectedAnswer(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config, accept bool) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	question, answer, found := findAnswerFromPath(response, request, QAEngineStore)
+	if !found || !verifyNotLocked(response, question) {
+		return
+	}
+
+	// Only the person who asked the question decides which answer solved it
+	if question.Username != claims.Username {
+		response.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Only the author of the question can accept an answer"})
+		return
+	}
+
+	previous := question.SelectedAnswer
+	selected := answer.ID
+	if !accept {
+		if !answer.ISSelected {
+			response.WriteHeader(http.StatusConflict)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: "Answer is not the accepted answer"})
+			return
+		}
+		selected = primitive.NilObjectID
+	}
+
+	question, err = QAEngineStore.Questions.SetSelectedAnswer(context.TODO(), question.ID, selected)
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Answer not found"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Failed to update the accepted answer"})
+		return
+	}
+
+	if !previous.ID.IsZero() && previous.ID != selected {
+		err = undoReputation(context.TODO(), QAEngineStore, previous.Username, model.ReputationAnswerAccepted, claims.Username, question.ID, previous.ID)
+	}
+	if err == nil && accept && previous.ID != selected {
+		err = awardReputation(context.TODO(), QAEngineStore, QAEngineConfig, answer.Username, model.ReputationAnswerAccepted, claims.Username, question.ID, answer.ID)
+	}
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Failed to update the reputation"})
+		return
+	}
+
+	message := "Answer accepted"
+	if !accept {
+		message = "Answer no longer accepted"
+	}
+	presentQuestion(&question, false)
+	if !attachReputation(response, QAEngineStore, &question) {
+		return
+	}
+	json.NewEncoder(response).Encode(ResultQuestion{Err: false, Message: message, Data: question})
+}
+
+// Looks up the question and the answer named in the path, writing the error response if either is missing.
+// Deleted answers count as missing
+func findAnswerFromPath(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) (model.Question, model.Answer, bool) {
+	return findAnswer(response, request, QAEngineStore, false)
+}
+
+func findAnswer(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, includeDeleted bool) (model.Question, model.Answer, bool) {
+	vars := mux.Vars(request)
+	question, err := findQuestion(QAEngineStore, vars["id"], "", "")
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Question not found"})
+		return question, model.Answer{}, false
+	} else if err != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return question, model.Answer{}, false
+	}
+
+	// The zero id is never given to an answer, it would only match answers stored before they had ids
+	answerID, err := primitive.ObjectIDFromHex(vars["answerid"])
+	if err != nil || answerID.IsZero() {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Invalid answer id"})
+		return question, model.Answer{}, false
+	}
+
+	for _, answer := range question.Answers {
+		if answer.ID == answerID && (includeDeleted || !answer.Deleted) {
+			return question, answer, true
+		}
+	}
+	response.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(response).Encode(Result{Err: true, Message: "Answer not found"})
+	return question, model.Answer{}, false
+}
+
+// Upvotes, downvotes or retracts the vote on a single answer. Every user holds one vote per answer
+func AddVoteToAnswer(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	var voteRequest AnswerVoteRequest
+	json.NewDecoder(request.Body).Decode(&voteRequest)
+	defer request.Body.Close()
+
+	vote, err := parseVoteType(voteRequest.VoteType)
+	if err != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	if vote == model.VoteDown && !verifyPrivilege(response, request, QAEngineStore, QAEngineConfig, claims, config.PrivilegeDownvote) {
+		return
+	}
+
+	question, answer, found := findAnswerFromPath(response, request, QAEngineStore)
+	if !found || !verifyNotLocked(response, question) {
+		return
+	}
+
+	result, err := castVote(QAEngineStore, QAEngineConfig, claims.Username, claims.Email, voteTarget{question: &question, answerID: answer.ID}, vote)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	json.NewEncoder(response).Encode(ResultVote{Err: false, Message: "Vote recorded", Data: result})
+}
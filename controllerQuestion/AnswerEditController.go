@@ -0,0 +1,276 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+)
+
+type EditAnswerRequest struct {
+	Answer string `json:"answer"`
+	// Why the answer was edited, shown in its history
+	Comment string `json:"comment"`
+}
+
+// The first revision of an answer, the answer as it was posted. It shares the ID of the answer
+func firstAnswerRevision(question model.Question, answer model.Answer) model.Revision {
+	return model.Revision{
+		ID:         answer.ID,
+		QuestionID: question.ID,
+		AnswerID:   answer.ID,
+		Number:     1,
+		Editor:     answer.Username,
+		CreatedAt:  answer.DatePosted,
+		Changed:    []string{model.RevisionContent},
+		Content:    answer.Answer,
+	}
+}
+
+// Returns the revisions of the answer, oldest first, made up from the answer when it was never edited
+func answerRevisions(QAEngineStore *store.Store, question model.Question, answer model.Answer) ([]model.Revision, error) {
+	revisions, err := QAEngineStore.Revisions.List(context.TODO(), question.ID, answer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		revisions = []model.Revision{firstAnswerRevision(question, answer)}
+	}
+	return revisions, nil
+}
+
+// Replaces the text of the answer in the path, keeping the previous text in its revisions.
+// Its author and the users with the edit privilege can edit it
+func EditAnswer(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	question, answer, found := findAnswerFromPath(response, request, QAEngineStore)
+	if !found || !verifyNotLocked(response, question) {
+		return
+	}
+	if answer.Username != claims.Username && !verifyPrivilege(response, request, QAEngineStore, QAEngineConfig, claims, config.PrivilegeEdit) {
+		return
+	}
+
+	var editRequest EditAnswerRequest
+	json.NewDecoder(request.Body).Decode(&editRequest)
+	defer request.Body.Close()
+
+	comment := strings.TrimSpace(editRequest.Comment)
+	if len(comment) > maxRevisionCommentLength {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The comment is limited to " + strconv.Itoa(maxRevisionCommentLength) + " characters"})
+		return
+	}
+	if strings.TrimSpace(editRequest.Answer) == "" {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The answer cannot be empty"})
+		return
+	}
+	if editRequest.Answer == answer.Answer {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The edit changes nothing"})
+		return
+	}
+
+	revisions, err := answerRevisions(QAEngineStore, question, answer)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	revision := model.Revision{
+		QuestionID: question.ID,
+		AnswerID:   answer.ID,
+		Editor:     claims.Username,
+		Comment:    comment,
+		Changed:    []string{model.RevisionContent},
+		Content:    editRequest.Answer,
+	}
+	if !insertRevision(response, QAEngineStore, revisions[len(revisions)-1], &revision) {
+		return
+	}
+
+	err = QAEngineStore.Questions.EditAnswer(context.TODO(), question.ID, answer.ID, editRequest.Answer)
+	if err != nil {
+		dropRevision(QAEngineStore, revision)
+	}
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Answer not found"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	answer.Answer = editRequest.Answer
+	answer.UpdatedAt = revision.CreatedAt
+	json.NewEncoder(response).Encode(ResultAnswer{Err: false, Message: "Answer edited", Data: answer})
+}
+
+// Deletes the answer in the path, leaving a tombstone moderators can undelete. Deleting the accepted
+// answer leaves the question without one. Its author loses the reputation the answer earned, from being
+// accepted and from its votes. The author of the answer and the moderators can delete it
+func DeleteAnswer(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	question, answer, found := findAnswerFromPath(response, request, QAEngineStore)
+	if !found || !verifyNotLocked(response, question) {
+		return
+	}
+	moderator := QAEngineConfig.IsModerator(claims.Username)
+	if answer.Username != claims.Username && !moderator {
+		response.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Only the author or a moderator can delete the answer"})
+		return
+	}
+
+	deleted := question
+	err = QAEngineStore.Transactions.Run(context.TODO(), func(ctx context.Context) error {
+		var err error
+		deleted, err = QAEngineStore.Questions.DeleteAnswer(ctx, question.ID, answer.ID, claims.Username)
+		if err != nil {
+			return err
+		}
+
+		// The answer is no longer accepted, and its author no longer earns for it
+		if answer.ISSelected {
+			err = undoReputation(ctx, QAEngineStore, answer.Username, model.ReputationAnswerAccepted, question.Username, question.ID, answer.ID)
+			if err != nil {
+				return err
+			}
+		}
+		return moveAnswerVotesReputation(ctx, QAEngineStore, QAEngineConfig, question, answer, false)
+	})
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Answer not found"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	question = deleted
+
+	presentQuestion(&question, moderator)
+	if !attachReputation(response, QAEngineStore, &question) {
+		return
+	}
+	json.NewEncoder(response).Encode(ResultQuestion{Err: false, Message: "Answer deleted", Data: question})
+}
+
+// Restores the deleted answer in the path, along with the reputation its votes earn its author. It comes
+// back unaccepted. Only moderators can undelete answers
+func UndeleteAnswer(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	if _, allowed := verifyModerator(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig); !allowed {
+		return
+	}
+
+	question, answer, found := findAnswer(response, request, QAEngineStore, true)
+	if !found {
+		return
+	}
+	if !answer.Deleted {
+		response.WriteHeader(http.StatusConflict)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Answer is not deleted"})
+		return
+	}
+
+	undeleted := question
+	err := QAEngineStore.Transactions.Run(context.TODO(), func(ctx context.Context) error {
+		var err error
+		undeleted, err = QAEngineStore.Questions.UndeleteAnswer(ctx, question.ID, answer.ID)
+		if err != nil {
+			return err
+		}
+		return moveAnswerVotesReputation(ctx, QAEngineStore, QAEngineConfig, question, answer, true)
+	})
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusConflict)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Answer is not deleted"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	question = undeleted
+
+	presentQuestion(&question, true)
+	if !attachReputation(response, QAEngineStore, &question) {
+		return
+	}
+	json.NewEncoder(response).Encode(ResultQuestion{Err: false, Message: "Answer undeleted", Data: question})
+}
+
+// Lists the revisions of the answer in the path, oldest first. Those of deleted answers are only shown to moderators
+func GetAnswerRevisions(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	question, answer, found := findAnswer(response, request, QAEngineStore, isModerator(request, QAEngineStore, QAEngineKeys, QAEngineConfig))
+	if !found {
+		return
+	}
+	revisions, err := answerRevisions(QAEngineStore, question, answer)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultRevisions{Err: false, Message: "Successfully fetched the revisions", Data: revisions})
+}
+
+// Returns a revision of the answer in the path with what it changed from the previous one
+func GetAnswerRevision(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	question, answer, found := findAnswer(response, request, QAEngineStore, isModerator(request, QAEngineStore, QAEngineKeys, QAEngineConfig))
+	if !found {
+		return
+	}
+	revisions, err := answerRevisions(QAEngineStore, question, answer)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	revision, found := revisionFromPath(response, request, revisions, "number")
+	if !found {
+		return
+	}
+
+	detail := RevisionDetail{Revision: revision}
+	if revision.Number > 1 {
+		revisionDiff := diffRevisions(revisions[revision.Number-2], revision)
+		detail.Diff = &revisionDiff
+	}
+	json.NewEncoder(response).Encode(ResultRevision{Err: false, Message: "Successfully fetched the revision", Data: detail})
+}
@@ -0,0 +1,350 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxCommentLength = 600
+
+// A mention is @ followed by a username, a trailing dot is taken for the end of the sentence.
+// The @ starts the text or follows a character a username cannot hold, so emails are not mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.-])@([A-Za-z0-9_.-]+)`)
+
+// AddCommentRequest posts a comment, as a reply to another comment on the same post when ReplyTo is set
+type AddCommentRequest struct {
+	Text    string `json:"text"`
+	ReplyTo string `json:"replyto"`
+}
+
+type EditCommentRequest struct {
+	Text string `json:"text"`
+}
+
+type ResultComment struct {
+	Err     bool          `json:"error"`
+	Message string        `json:"message"`
+	Data    model.Comment `json:"data"`
+}
+
+type ResultComments struct {
+	Err     bool            `json:"error"`
+	Message string          `json:"message"`
+	Data    []model.Comment `json:"data"`
+}
+
+// Returns the registered users mentioned in the text, each once, leaving out the author
+func findMentions(QAEngineStore *store.Store, text string, author string) ([]string, error) {
+	mentions := []string{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		username := strings.TrimRight(match[1], ".")
+		if username == "" || username == author || containsString(mentions, username) {
+			continue
+		}
+		_, err := QAEngineStore.Users.FindByUsername(context.TODO(), username)
+		if err == store.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, username)
+	}
+	return mentions, nil
+}
+
+// Checks the text of a comment, writing the error response when it is empty or too long
+func commentText(response http.ResponseWriter, text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The comment cannot be empty"})
+		return text, false
+	}
+	if utf8.RuneCountInString(text) > maxCommentLength {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Comments are limited to " + strconv.Itoa(maxCommentLength) + " characters"})
+		return text, false
+	}
+	return text, true
+}
+
+// Finds the post the comments in the path are on, the answer when the path names one and the
+// question otherwise. The answer ID is zero for the question
+func commentTarget(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) (model.Question, primitive.ObjectID, bool) {
+	if mux.Vars(request)["answerid"] == "" {
+		question, found := questionFromPath(response, request, QAEngineStore)
+		return question, primitive.NilObjectID, found
+	}
+	question, answer, found := findAnswerFromPath(response, request, QAEngineStore)
+	return question, answer.ID, found
+}
+
+// Reports whether the user posted the answer of the question, false for a zero answer ID
+func isAnswerAuthor(question model.Question, answerID primitive.ObjectID, username string) bool {
+	for _, answer := range question.Answers {
+		if answer.ID == answerID {
+			return answer.Username == username
+		}
+	}
+	return false
+}
+
+// Finds the comment in the path along with its question, writing the error response when either is missing
+func commentFromPath(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) (model.Comment, model.Question, bool) {
+	id, err := primitive.ObjectIDFromHex(mux.Vars(request)["commentid"])
+	if err != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Invalid comment id"})
+		return model.Comment{}, model.Question{}, false
+	}
+
+	comment, err := QAEngineStore.Comments.FindByID(context.TODO(), id)
+	var question model.Question
+	if err == nil {
+		question, err = findQuestionByID(QAEngineStore, comment.QuestionID.Hex(), false)
+	}
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Comment not found"})
+		return comment, question, false
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return comment, question, false
+	}
+	return comment, question, true
+}
+
+// Fills in the comments of the question and of its answers
+func attachComments(QAEngineStore *store.Store, question *model.Question) error {
+	comments, err := QAEngineStore.Comments.ListByQuestion(context.TODO(), question.ID)
+	if err != nil {
+		return err
+	}
+
+	byAnswer := map[primitive.ObjectID][]model.Comment{}
+	for _, comment := range comments {
+		byAnswer[comment.AnswerID] = append(byAnswer[comment.AnswerID], comment)
+	}
+	question.Comments = byAnswer[primitive.NilObjectID]
+	for i := range question.Answers {
+		question.Answers[i].Comments = byAnswer[question.Answers[i].ID]
+	}
+	if !question.SelectedAnswer.ID.IsZero() {
+		question.SelectedAnswer.Comments = byAnswer[question.SelectedAnswer.ID]
+	}
+	return nil
+}
+
+// Lists the comments on the question in the path, or on its answer when the path names one, oldest first.
+// Replies carry the id of the comment they reply to so clients can thread them
+func GetComments(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+
+	question, answerID, found := commentTarget(response, request, QAEngineStore)
+	if !found {
+		return
+	}
+
+	comments, err := QAEngineStore.Comments.List(context.TODO(), question.ID, answerID)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultComments{Err: false, Message: "Successfully fetched the comments", Data: comments})
+}
+
+// Comments on the question in the path, or on its answer when the path names one.
+// Registered users mentioned as @username in the text are recorded with the comment.
+// Commenting needs the comment privilege, except on your own question and the answers to it and on your own answers
+func AddComment(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	question, answerID, found := commentTarget(response, request, QAEngineStore)
+	if !found || !verifyNotLocked(response, question) {
+		return
+	}
+	if question.Username != claims.Username && !isAnswerAuthor(question, answerID, claims.Username) &&
+		!verifyPrivilege(response, request, QAEngineStore, QAEngineConfig, claims, config.PrivilegeComment) {
+		return
+	}
+
+	var commentRequest AddCommentRequest
+	json.NewDecoder(request.Body).Decode(&commentRequest)
+	defer request.Body.Close()
+
+	text, valid := commentText(response, commentRequest.Text)
+	if !valid {
+		return
+	}
+
+	comment := model.Comment{
+		QuestionID: question.ID,
+		AnswerID:   answerID,
+		Username:   claims.Username,
+		Text:       text,
+		CreatedAt:  time.Now(),
+		Upvoters:   []string{},
+	}
+
+	if commentRequest.ReplyTo != "" {
+		replyTo, err := primitive.ObjectIDFromHex(commentRequest.ReplyTo)
+		var parent model.Comment
+		if err == nil {
+			parent, err = QAEngineStore.Comments.FindByID(context.TODO(), replyTo)
+		}
+		if err != nil || parent.QuestionID != question.ID || parent.AnswerID != answerID {
+			response.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(response).Encode(invalidParameter("replyto", commentRequest.ReplyTo, "Replies are to another comment on the same post"))
+			return
+		}
+		comment.ReplyTo = &parent.ID
+	}
+
+	comment.Mentions, err = findMentions(QAEngineStore, text, claims.Username)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	if err = QAEngineStore.Comments.Insert(context.TODO(), &comment); err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Failed to add the comment"})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultComment{Err: false, Message: "Comment added", Data: comment})
+}
+
+// Replaces the text of the comment in the path. Only its author can edit it, and only for a while after posting it
+func EditComment(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	comment, question, found := commentFromPath(response, request, QAEngineStore)
+	if !found || !verifyNotLocked(response, question) {
+		return
+	}
+	if comment.Username != claims.Username {
+		response.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Only the author can edit the comment"})
+		return
+	}
+	if time.Since(comment.CreatedAt) > QAEngineConfig.CommentEditWindow {
+		response.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Comments can only be edited within " + QAEngineConfig.CommentEditWindow.String() + " of posting them"})
+		return
+	}
+
+	var editRequest EditCommentRequest
+	json.NewDecoder(request.Body).Decode(&editRequest)
+	defer request.Body.Close()
+
+	text, valid := commentText(response, editRequest.Text)
+	if !valid {
+		return
+	}
+	if text == comment.Text {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The edit changes nothing"})
+		return
+	}
+
+	mentions, err := findMentions(QAEngineStore, text, claims.Username)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	comment, err = QAEngineStore.Comments.Edit(context.TODO(), comment.ID, text, mentions)
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Comment not found"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultComment{Err: false, Message: "Comment edited", Data: comment})
+}
+
+// Upvotes the comment in the path, once per user
+func UpvoteComment(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager) {
+	setCommentUpvote(response, request, QAEngineStore, QAEngineKeys, true)
+}
+
+// Withdraws the upvote of the user from the comment in the path
+func RemoveCommentUpvote(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager) {
+	setCommentUpvote(response, request, QAEngineStore, QAEngineKeys, false)
+}
+
+func setCommentUpvote(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, upvote bool) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	comment, question, found := commentFromPath(response, request, QAEngineStore)
+	if !found || !verifyNotLocked(response, question) {
+		return
+	}
+	if comment.Username == claims.Username {
+		response.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "You cannot upvote your own comment"})
+		return
+	}
+
+	comment, err = QAEngineStore.Comments.Upvote(context.TODO(), comment.ID, claims.Username, upvote)
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Comment not found"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Failed to record the upvote"})
+		return
+	}
+
+	message := "Comment upvoted"
+	if !upvote {
+		message = "Upvote withdrawn"
+	}
+	json.NewEncoder(response).Encode(ResultComment{Err: false, Message: message, Data: comment})
+}
@@ -0,0 +1,41 @@
+package controllerQuestion
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"example.org/model"
+	"example.org/store"
+)
+
+func TestFindMentions(t *testing.T) {
+	QAEngineStore := store.NewMemoryStore()
+	for _, username := range []string{"alice", "bob", "example.com", "c.d"} {
+		user := model.UserModel{Username: username, Email: username + "@mail.org"}
+		if err := QAEngineStore.Users.Insert(context.Background(), &user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string]string{
+		"@alice look":                     "[alice]",
+		"thanks @bob.":                    "[bob]",
+		"(@alice,@bob) and @alice again":  "[alice bob]",
+		"@carol is not registered":        "[]",
+		"write to someone@example.com":    "[]",
+		"mail c.d@bob.org or @c.d":        "[c.d]",
+		"me@alice and @me":                "[]",
+		"@dave: @bob\n@example.com":       "[bob example.com]",
+		"asked by @author about @bob@bob": "[bob]",
+	}
+	for text, want := range cases {
+		mentions, err := findMentions(QAEngineStore, text, "author")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(mentions); got != want {
+			t.Errorf("findMentions(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"example.org/config"
+	"example.org/search"
+	"example.org/store"
+)
+
+const (
+	defaultDuplicateLimit = 5
+	maxDuplicateLimit     = 20
+	// Questions less similar than this are not worth showing as possible duplicates
+	minDuplicateSimilarity = 0.3
+)
+
+// Duplicate is an existing question worded like the one being asked
+type Duplicate struct {
+	QuestionID string  `json:"questionid"`
+	Title      string  `json:"title"`
+	Similarity float64 `json:"similarity"`
+	// Whether the similarity reaches the duplicate threshold, which AddQuestion acts upon
+	Likely bool `json:"likely"`
+}
+
+type ResultDuplicates struct {
+	Err     bool        `json:"error"`
+	Message string      `json:"message"`
+	Data    []Duplicate `json:"data"`
+}
+
+// Finds the questions at least minimum similar to the title and content, the most similar first
+func findDuplicates(QAEngineStore *store.Store, QAEngineConfig *config.Config, title string, content string, minimum float64, limit int) ([]Duplicate, error) {
+	duplicates := []Duplicate{}
+	for _, hit := range QAEngineStore.Search.Similar(title, content, minimum, limit) {
+		question, err := QAEngineStore.Questions.FindByID(context.TODO(), hit.ID)
+		if err == store.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, Duplicate{
+			QuestionID: question.ID.Hex(),
+			Title:      question.Title,
+			Similarity: hit.Score,
+			Likely:     search.Reaches(hit.Score, QAEngineConfig.DuplicateThreshold),
+		})
+	}
+	return duplicates, nil
+}
+
+// Lists the existing questions worded like the title and content parameters, meant to be called
+// while the question is being typed
+func CheckDuplicates(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+	parameters := request.URL.Query()
+
+	title, content := parameters.Get("title"), parameters.Get("content")
+	if title == "" && content == "" {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("title", "", "A title or a content is needed"))
+		return
+	}
+
+	limit := defaultDuplicateLimit
+	if value := parameters.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxDuplicateLimit {
+			response.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(response).Encode(invalidParameter("limit", value, "limit must be a number between 1 and "+strconv.Itoa(maxDuplicateLimit)))
+			return
+		}
+		limit = parsed
+	}
+
+	duplicates, err := findDuplicates(QAEngineStore, QAEngineConfig, title, content, minDuplicateSimilarity, limit)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultDuplicates{Err: false, Message: "Successfully checked for duplicates", Data: duplicates})
+}
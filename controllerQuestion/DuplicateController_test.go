@@ -0,0 +1,51 @@
+package controllerQuestion
+
+import (
+	"testing"
+
+	"example.org/config"
+	"example.org/store"
+)
+
+// Possible duplicates are listed down to the minimum, only those at the configured threshold are likely
+func TestFindDuplicates(t *testing.T) {
+	QAEngineStore := store.NewMemoryStore()
+	QAEngineConfig := config.Default()
+	askQuestion(t, QAEngineStore, "parse json into a struct", 0)
+	askQuestion(t, QAEngineStore, "parse json into a map", 0)
+	askQuestion(t, QAEngineStore, "bake a cake", 0)
+
+	duplicates, err := findDuplicates(QAEngineStore, &QAEngineConfig, "Parse JSON into a struct", "", minDuplicateSimilarity, defaultDuplicateLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(duplicates) != 2 {
+		t.Fatalf("duplicates = %+v, want the two json questions", duplicates)
+	}
+	if duplicates[0].Title != "parse json into a struct" || !duplicates[0].Likely {
+		t.Errorf("first duplicate = %+v, want the same question, likely", duplicates[0])
+	}
+	if duplicates[1].Likely || duplicates[1].Similarity < minDuplicateSimilarity {
+		t.Errorf("second duplicate = %+v, want a possible one only", duplicates[1])
+	}
+
+	// The threshold is inclusive, a question exactly at it is likely
+	QAEngineConfig.DuplicateThreshold = duplicates[1].Similarity
+	duplicates, err = findDuplicates(QAEngineStore, &QAEngineConfig, "Parse JSON into a struct", "", minDuplicateSimilarity, defaultDuplicateLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(duplicates) != 2 || !duplicates[1].Likely {
+		t.Errorf("duplicates at a threshold of %v = %+v, want both likely", QAEngineConfig.DuplicateThreshold, duplicates)
+	}
+
+	// AddQuestion only looks from the threshold up
+	QAEngineConfig.DuplicateThreshold = 1
+	duplicates, err = findDuplicates(QAEngineStore, &QAEngineConfig, "parse json into a struct", "", QAEngineConfig.DuplicateThreshold, defaultDuplicateLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(duplicates) != 1 || !duplicates[0].Likely {
+		t.Errorf("duplicates at a threshold of 1 = %+v, want the identical question", duplicates)
+	}
+}
@@ -0,0 +1,207 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.org/config"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+type ResultPage struct {
+	Err        bool             `json:"error"`
+	Message    string           `json:"message"`
+	Data       []model.Question `json:"data"`
+	NextCursor string           `json:"next_cursor"`
+	HasMore    bool             `json:"has_more"`
+}
+
+// ResultInvalidParameter names the query parameter that was rejected and, when they are known,
+// the values it accepts
+type ResultInvalidParameter struct {
+	Err       bool     `json:"error"`
+	Message   string   `json:"message"`
+	Parameter string   `json:"parameter"`
+	Value     string   `json:"value"`
+	Allowed   []string `json:"allowed,omitempty"`
+}
+
+func invalidParameter(parameter string, value string, message string, allowed ...string) *ResultInvalidParameter {
+	return &ResultInvalidParameter{Err: true, Message: message, Parameter: parameter, Value: value, Allowed: allowed}
+}
+
+var errInvalidCursor = errors.New("Invalid cursor")
+
+// Cursors are handed to the clients as opaque strings, the base64 of the JSON position
+func encodeCursor(cursor store.QuestionCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string, query store.QuestionQuery) (*store.QuestionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var cursor store.QuestionCursor
+	if err = json.Unmarshal(data, &cursor); err != nil || cursor.ID.IsZero() {
+		return nil, errInvalidCursor
+	}
+	// A cursor only means something in the order it was taken from
+	if cursor.Sort != query.Sort || cursor.Descending != query.Descending {
+		return nil, errors.New("The cursor belongs to a different sort order")
+	}
+	return &cursor, nil
+}
+
+// Reads a time parameter, either an RFC 3339 time or a date which stands for its midnight in UTC.
+// The zero time is returned when the parameter is absent
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("Times must be RFC 3339 times or dates like 2006-01-02")
+	}
+	return parsed, nil
+}
+
+// Reads the sort parameter of a listing request, writing the error response when it names no sort order
+func sortFromRequest(response http.ResponseWriter, request *http.Request, defaultSort string) (string, bool) {
+	sort := request.URL.Query().Get("sort")
+	if sort == "" {
+		return defaultSort, true
+	}
+
+	if !store.IsQuestionSort(sort) {
+		response.Header().Add("Content-Type", "application/json")
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("sort", sort, "Unknown sort order "+strconv.Quote(sort), store.QuestionSorts...))
+		return sort, false
+	}
+	return sort, true
+}
+
+// Reads the order, limit, cursor, since, until, tags, match and state parameters of a listing request.
+// since and until select the questions asked in the range, since included and until excluded.
+// state is a comma separated list of states, every state but deleted by default
+func parseQuestionQuery(request *http.Request, sort string, descending bool) (store.QuestionQuery, *ResultInvalidParameter) {
+	query := request.URL.Query()
+	questionQuery := store.QuestionQuery{Sort: sort, Descending: descending, Limit: defaultPageLimit}
+
+	switch order := query.Get("order"); order {
+	case "":
+	case "asc":
+		questionQuery.Descending = false
+	case "desc":
+		questionQuery.Descending = true
+	default:
+		return questionQuery, invalidParameter("order", order, "Unknown order "+strconv.Quote(order), "asc", "desc")
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > maxPageLimit {
+			return questionQuery, invalidParameter("limit", limit, "limit must be a number between 1 and "+strconv.Itoa(maxPageLimit))
+		}
+		questionQuery.Limit = value
+	}
+
+	var err error
+	if questionQuery.Since, err = parseTime(query.Get("since")); err != nil {
+		return questionQuery, invalidParameter("since", query.Get("since"), err.Error())
+	}
+	if questionQuery.Until, err = parseTime(query.Get("until")); err != nil {
+		return questionQuery, invalidParameter("until", query.Get("until"), err.Error())
+	}
+
+	if invalid := parseTagFilter(request, &questionQuery); invalid != nil {
+		return questionQuery, invalid
+	}
+
+	if states := query.Get("state"); states != "" {
+		for _, state := range strings.Split(states, ",") {
+			state = strings.TrimSpace(state)
+			if !containsString(model.QuestionStates, state) {
+				return questionQuery, invalidParameter("state", state, "Unknown state "+strconv.Quote(state), model.QuestionStates...)
+			}
+			questionQuery.States = append(questionQuery.States, state)
+		}
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := decodeCursor(cursor, questionQuery)
+		if err != nil {
+			return questionQuery, invalidParameter("cursor", cursor, err.Error())
+		}
+		questionQuery.After = after
+	}
+	return questionQuery, nil
+}
+
+// Writes one page of the questions listed in the given order, the order parameter may reverse it.
+// Only moderators can list the deleted questions
+func listQuestions(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config, sort string, descending bool) {
+	response.Header().Add("Content-Type", "application/json")
+
+	query, invalid := parseQuestionQuery(request, sort, descending)
+	if invalid != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalid)
+		return
+	}
+	if containsString(query.States, model.QuestionDeleted) && !isModerator(request, QAEngineStore, QAEngineKeys, QAEngineConfig) {
+		response.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Only moderators can list the deleted questions"})
+		return
+	}
+	writeQuestionPage(response, QAEngineStore, query)
+}
+
+// Writes the page of questions the query selects, with the cursor of the next page when there is one
+func writeQuestionPage(response http.ResponseWriter, QAEngineStore *store.Store, query store.QuestionQuery) {
+	questions, hasMore, err := QAEngineStore.Questions.List(context.TODO(), query)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	page := ResultPage{
+		Err:     false,
+		Message: "Successfully fetched all questions",
+		Data:    questions,
+		HasMore: hasMore,
+	}
+	if hasMore {
+		page.NextCursor = encodeCursor(store.CursorOf(query, questions[len(questions)-1]))
+	}
+
+	presentQuestions(questions)
+	pointers := make([]*model.Question, len(questions))
+	for i := range questions {
+		pointers[i] = &questions[i]
+	}
+	if !attachReputation(response, QAEngineStore, pointers...) {
+		return
+	}
+	json.NewEncoder(response).Encode(page)
+}
@@ -0,0 +1,88 @@
+package controllerQuestion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.org/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	query := store.QuestionQuery{Sort: store.SortTop, Descending: true}
+	cursor := store.QuestionCursor{Sort: store.SortTop, Descending: true, Key: 12, ID: primitive.NewObjectID()}
+
+	decoded, err := decodeCursor(encodeCursor(cursor), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != cursor {
+		t.Errorf("decoded %+v, want %+v", *decoded, cursor)
+	}
+}
+
+// A cursor taken from one order cannot be used in another, where its position means nothing
+func TestCursorFromAnotherSort(t *testing.T) {
+	cursor := encodeCursor(store.QuestionCursor{Sort: store.SortNewest, ID: primitive.NewObjectID()})
+
+	queries := map[string]store.QuestionQuery{
+		"other sort":      {Sort: store.SortTop},
+		"other direction": {Sort: store.SortNewest, Descending: true},
+	}
+	for name, query := range queries {
+		if _, err := decodeCursor(cursor, query); err == nil {
+			t.Errorf("%s: cursor accepted", name)
+		}
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	query := store.QuestionQuery{Sort: store.SortNewest}
+	for _, value := range []string{"not base64!", encodeCursor(store.QuestionCursor{Sort: store.SortNewest}), "bm90IGpzb24"} {
+		if _, err := decodeCursor(value, query); err == nil {
+			t.Errorf("cursor %q accepted", value)
+		}
+	}
+}
+
+// The listing parameters are read into the query, the cursor checked against the order they select
+func TestParseQuestionQuery(t *testing.T) {
+	cursor := encodeCursor(store.QuestionCursor{Sort: store.SortNewest, ID: primitive.NewObjectID()})
+
+	request := httptest.NewRequest("GET", "/questions?limit=5&order=asc&cursor="+cursor, nil)
+	query, invalid := parseQuestionQuery(request, store.SortNewest, false)
+	if invalid != nil {
+		t.Fatalf("rejected %s: %s", invalid.Parameter, invalid.Message)
+	}
+	if query.Limit != 5 || query.Descending || query.After == nil {
+		t.Errorf("query = %+v", query)
+	}
+
+	// order=desc turns the same cursor into one of another order
+	request = httptest.NewRequest("GET", "/questions?order=desc&cursor="+cursor, nil)
+	if _, invalid = parseQuestionQuery(request, store.SortNewest, false); invalid == nil || invalid.Parameter != "cursor" {
+		t.Errorf("cursor of the ascending order accepted in the descending one")
+	}
+
+	for _, parameters := range []string{"limit=0", "limit=101", "order=up", "since=yesterday"} {
+		request = httptest.NewRequest("GET", "/questions?"+parameters, nil)
+		if _, invalid = parseQuestionQuery(request, store.SortNewest, false); invalid == nil {
+			t.Errorf("%s accepted", parameters)
+		}
+	}
+}
+
+func TestSortFromRequest(t *testing.T) {
+	for query, want := range map[string]string{"": store.SortTop, "?sort=hot": store.SortHot, "?sort=most-answered": store.SortMostAnswered} {
+		sort, found := sortFromRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/questions"+query, nil), store.SortTop)
+		if !found || sort != want {
+			t.Errorf("%q: sort %q, want %q", query, sort, want)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	if _, found := sortFromRequest(recorder, httptest.NewRequest("GET", "/questions?sort=best", nil), store.SortTop); found || recorder.Code != http.StatusBadRequest {
+		t.Errorf("unknown sort: found %v, status %d", found, recorder.Code)
+	}
+}
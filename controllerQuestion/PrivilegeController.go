@@ -0,0 +1,34 @@
+package controllerQuestion
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+)
+
+// The body answering a request the middlewares refused, naming the missing privilege when that was why
+func refusal(err error) interface{} {
+	if missing, ok := err.(*middlewares.MissingPrivilege); ok {
+		return missing
+	}
+	return Result{Err: true, Message: err.Error()}
+}
+
+// Checks that the logged in user holds the privilege, writing the error response when not
+func verifyPrivilege(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineConfig *config.Config, claims *model.Claims, privilege string) bool {
+	err := middlewares.CheckPrivilege(request.Context(), QAEngineStore, QAEngineConfig, claims, privilege)
+	if missing, ok := err.(*middlewares.MissingPrivilege); ok {
+		response.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(response).Encode(missing)
+		return false
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return false
+	}
+	return true
+}
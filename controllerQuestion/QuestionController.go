@@ -4,31 +4,37 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 
 	// "fmt"
 	"net/http"
 	"time"
+	"example.org/config"
     "example.org/middlewares"
 	"example.org/model"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"example.org/store"
+	"example.org/tokens"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type RequestQuestion struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Title    string `json:"title"`
-	Content  string `json:"content"`
+	// The author is filled in from the token claims, never from the request body
+	Username string `json:"-"`
+	Email    string `json:"-"`
+	Title    string   `json:"title"`
+	Content  string   `json:"content"`
+	Tags     []string `json:"tags"`
 }
 
 type UpVoteRequestQuestion struct {
+	QuestionID   string `json:"questionid"`
 	Username     string `json:"username"`
 	Email        string `json:"email"`
 	Title        string `json:"title"`
 	Content      string `json:"content"`
-	VoteUsername string `json:"voteusername"`
-	VoteEmail    string `json:"voteemail"`
+	// The voter is filled in from the token claims, never from the request body
+	VoteUsername string `json:"-"`
+	VoteEmail    string `json:"-"`
 	VoteType     string `json:"votetype"`
 }
 
@@ -43,17 +49,41 @@ type ResultSuccess struct {
 	Data    []model.Question `json:"data"`
 }
 
+type ResultQuestion struct {
+	Err     bool           `json:"error"`
+	Message string         `json:"message"`
+	Data    model.Question `json:"data"`
+}
+
+// ResultAddedQuestion is the question just added along with the existing questions it likely duplicates
+type ResultAddedQuestion struct {
+	Err        bool           `json:"error"`
+	Message    string         `json:"message"`
+	Data       model.Question `json:"data"`
+	Duplicates []Duplicate    `json:"duplicates,omitempty"`
+}
+
+type ResultAnswer struct {
+	Err     bool         `json:"error"`
+	Message string       `json:"message"`
+	Data    model.Answer `json:"data"`
+}
+
 type AnswerRequestQuestion struct {
-	AnswerUsername   string `json:"answerusername"`
-	AnswerEmail      string `json:"answeremail"`
+	QuestionID       string `json:"questionid"`
+	// The author of the answer is filled in from the token claims, never from the request body
+	AnswerUsername   string `json:"-"`
+	AnswerEmail      string `json:"-"`
 	QuestionUsername string `json:"questionusername"`
 	QuestionEmail    string `json:"questionemail"`
 	Answer           string `json:"answer"`
 	Title            string `json:"title"`
 }
 
-func AddQuestion(response http.ResponseWriter, request *http.Request, QAEngineDatabase *mongo.Database) {
-	err := middlewares.VerifyRequest(response, request)
+// Adds the question of the logged in user. A question worded like an existing one is refused or added with
+// the list of its likely duplicates, depending on the configured duplicate action
+func AddQuestion(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
 
 	// Unauthorized access
 	if err != nil {
@@ -68,23 +98,49 @@ func AddQuestion(response http.ResponseWriter, request *http.Request, QAEngineDa
 
 	var questionDetails RequestQuestion
 	json.NewDecoder(request.Body).Decode(&questionDetails)
+	questionDetails.Username = claims.Username
+	questionDetails.Email = claims.Email
+
+	tags, invalid := normalizeTags(questionDetails.Tags)
+	if invalid != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalid)
+		return
+	}
+	questionDetails.Tags, err = applyTagSynonyms(QAEngineStore, tags)
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Error adding question"})
+		return
+	}
 	var person model.UserModel
-	err = checkUserInDatabase(&questionDetails, QAEngineDatabase, &person)
+	err = checkUserInDatabase(&questionDetails, QAEngineStore, &person)
 	if err != nil {
 		// User is not present in the database
 		json.NewEncoder(response).Encode(Result{Err: false, Message: "User not present in the database"})
 	} else {
 		// Check if the question with the given title is present or not
 
-		err = checkQuestionInDatabase(&questionDetails, QAEngineDatabase)
+		err = checkQuestionInDatabase(&questionDetails, QAEngineStore)
 		if err == nil {
+			// Look for the same question asked in other words
+			duplicates, err := findDuplicates(QAEngineStore, QAEngineConfig, questionDetails.Title, questionDetails.Content, QAEngineConfig.DuplicateThreshold, defaultDuplicateLimit)
+			if err != nil {
+				json.NewEncoder(response).Encode(Result{Err: true, Message: "Error adding question"})
+				return
+			}
+			if len(duplicates) > 0 && QAEngineConfig.DuplicateAction == "reject" {
+				response.WriteHeader(http.StatusConflict)
+				json.NewEncoder(response).Encode(ResultDuplicates{Err: true, Message: "The question looks like a duplicate of an existing one", Data: duplicates})
+				return
+			}
+
 			// Valid question
 			// Add the new question to the database
 
-			_, error := addQuestionToDatabase(&questionDetails, QAEngineDatabase)
+			question, error := addQuestionToDatabase(&questionDetails, QAEngineStore)
 			if error == nil {
 				// Successfully added question to the database
-				json.NewEncoder(response).Encode(Result{Err: false, Message: "Added question successfully"})
+				json.NewEncoder(response).Encode(ResultAddedQuestion{Err: false, Message: "Added question successfully", Data: question, Duplicates: duplicates})
 
 			} else {
 				// Error adding the question to the database
@@ -99,29 +155,28 @@ func AddQuestion(response http.ResponseWriter, request *http.Request, QAEngineDa
 	defer request.Body.Close()
 }
 
-func checkUserInDatabase(questionDetails *RequestQuestion, QAEngineDatabase *mongo.Database, person *model.UserModel) error {
-	error := QAEngineDatabase.Collection("users").FindOne(context.TODO(), bson.M{
-		"email":    questionDetails.Email,
-		"username": questionDetails.Username,
-	}).Decode(&person)
+func checkUserInDatabase(questionDetails *RequestQuestion, QAEngineStore *store.Store, person *model.UserModel) error {
+	user, error := QAEngineStore.Users.FindByUsernameAndEmail(context.TODO(), questionDetails.Username, questionDetails.Email)
 
 	if error != nil {
 		// Error finding an user
 		return errors.New("User not found in that database")
 	} else {
+		person.Username = user.Username
+		person.Email = user.Email
+		person.Country = user.Country
+		person.Phone = user.Phone
+		person.City = user.City
 		return nil
 	}
 }
 
-func checkQuestionInDatabase(questionDetails *RequestQuestion, QAEngineDatabase *mongo.Database) error {
-	var question model.Question
-	result := QAEngineDatabase.Collection("questions").FindOne(context.TODO(), bson.M{
-		"title": questionDetails.Title,
-	}).Decode(&question)
+func checkQuestionInDatabase(questionDetails *RequestQuestion, QAEngineStore *store.Store) error {
+	_, result := QAEngineStore.Questions.FindByTitle(context.TODO(), questionDetails.Title)
 
 	if result != nil {
-		// ErrNoDocuments means that the filter did not match any documents in the collection
-		if result == mongo.ErrNoDocuments {
+		// ErrNotFound means that the filter did not match any documents in the collection
+		if result == store.ErrNotFound {
 			return nil
 		}
 
@@ -130,36 +185,111 @@ func checkQuestionInDatabase(questionDetails *RequestQuestion, QAEngineDatabase
 	return errors.New("Question with that title already found in the database")
 }
 
-func addQuestionToDatabase(questionDetails *RequestQuestion, QAEngineDatabase *mongo.Database) (*mongo.InsertOneResult, error) {
+func addQuestionToDatabase(questionDetails *RequestQuestion, QAEngineStore *store.Store) (model.Question, error) {
 	newQuestion := model.Question{}
 	newQuestion.Username = questionDetails.Username
 	newQuestion.Content = questionDetails.Content
 	newQuestion.Votes = 0
 	newQuestion.Answers = []model.Answer{}
 	newQuestion.Title = questionDetails.Title
+	newQuestion.Tags = questionDetails.Tags
 	newQuestion.SelectedAnswer = model.Answer{}
+	newQuestion.CreatedAt = time.Now()
+	newQuestion.UpdatedAt = newQuestion.CreatedAt
+	newQuestion.LastActivityAt = newQuestion.CreatedAt
+	newQuestion.Status = model.QuestionStatus{State: model.QuestionOpen, ChangedBy: newQuestion.Username, ChangedAt: newQuestion.CreatedAt}
+
+	err := QAEngineStore.Tags.EnsureTags(context.TODO(), newQuestion.Tags)
+	if err != nil {
+		return model.Question{}, errors.New("Error adding the tags of the question")
+	}
 
-	result, err := QAEngineDatabase.Collection("questions").InsertOne(context.TODO(), newQuestion)
+	err = QAEngineStore.Questions.Insert(context.TODO(), &newQuestion)
 
 	if err != nil {
 		// Error adding question
-		return nil, errors.New("Error adding question to the database")
+		return model.Question{}, errors.New("Error adding question to the database")
 	} else {
-		return result, nil
+		return newQuestion, nil
+	}
+
+}
+
+// Finds the question a request refers to. Requests that carry a question id are resolved by it,
+// older clients that only send the title (and optionally the author) are resolved the legacy way.
+// Deleted questions are not found
+func findQuestion(QAEngineStore *store.Store, questionID string, username string, title string) (model.Question, error) {
+	var question model.Question
+	var err error
+	if questionID != "" {
+		return findQuestionByID(QAEngineStore, questionID, false)
+	} else if username != "" {
+		question, err = QAEngineStore.Questions.FindByUsernameAndTitle(context.TODO(), username, title)
+	} else {
+		question, err = QAEngineStore.Questions.FindByTitle(context.TODO(), title)
+	}
+	if err == nil && question.CurrentState() == model.QuestionDeleted {
+		return model.Question{}, store.ErrNotFound
+	}
+	return question, err
+}
+
+// Finds the question with the given id, the deleted one only when includeDeleted is set
+func findQuestionByID(QAEngineStore *store.Store, questionID string, includeDeleted bool) (model.Question, error) {
+	id, err := primitive.ObjectIDFromHex(questionID)
+	if err != nil {
+		return model.Question{}, errors.New("Invalid question id")
+	}
+	question, err := QAEngineStore.Questions.FindByID(context.TODO(), id)
+	if err == nil && !includeDeleted && question.CurrentState() == model.QuestionDeleted {
+		return model.Question{}, store.ErrNotFound
 	}
+	return question, err
+}
 
+// Gets a single question by its id with the comments on it and on its answers.
+// Moderators also get the deleted questions and answers
+func GetQuestion(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	moderator := isModerator(request, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	question, err := findQuestionByID(QAEngineStore, mux.Vars(request)["id"], moderator)
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Question not found"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	presentQuestion(&question, moderator)
+	if !attachReputation(response, QAEngineStore, &question) {
+		return
+	}
+	if err = attachComments(QAEngineStore, &question); err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultQuestion{
+		Err:     false,
+		Message: "Successfully fetched the question",
+		Data:    question,
+	})
 }
 
-func AddAnswer(response http.ResponseWriter, request *http.Request, QAEngineDatabase *mongo.Database) {
-	// The body of the request should contain username, email of the person who is answering the question
-	// and the title of the question as the title is unique in the database
-	// 1. Get the username and email of the person who is posting the answer
+func AddAnswer(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager) {
+	// The person answering the question is taken from the token, the body of the request should contain
+	// the id or the title of the question as the title is unique in the database
+	// 1. Get the username and email of the person who is posting the answer from the token claims
 	// 2. Get the email and username of the person who has posted the question
 	// 3. Get the userid of the person who is posting the answer
 	// 4. Get the answer content for the question
 	// Steps 1, 2 ,4
 
-	err := middlewares.VerifyRequest(response, request)
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
 
 	// Unauthorized access
 	if err != nil {
@@ -173,9 +303,38 @@ func AddAnswer(response http.ResponseWriter, request *http.Request, QAEngineData
 	// Authorized
 	var answerRequestDetails AnswerRequestQuestion
 	json.NewDecoder(request.Body).Decode(&answerRequestDetails)
+	answerRequestDetails.AnswerUsername = claims.Username
+	answerRequestDetails.AnswerEmail = claims.Email
+
+	answerQuestion(response, QAEngineStore, &answerRequestDetails)
+}
+
+// Adds an answer to the question identified by the id in the path
+func AddAnswerToQuestion(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager) {
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{
+			Err : false,
+			Message : err.Error(),
+		})
+		return
+	}
+
+	// Authorized
+	var answerRequestDetails AnswerRequestQuestion
+	json.NewDecoder(request.Body).Decode(&answerRequestDetails)
+	answerRequestDetails.AnswerUsername = claims.Username
+	answerRequestDetails.AnswerEmail = claims.Email
+	answerRequestDetails.QuestionID = mux.Vars(request)["id"]
 
+	answerQuestion(response, QAEngineStore, &answerRequestDetails)
+}
+
+func answerQuestion(response http.ResponseWriter, QAEngineStore *store.Store, answerRequestDetails *AnswerRequestQuestion) {
 	// Step 3
-	result, err := getUserId(QAEngineDatabase, &answerRequestDetails)
+	result, err := getUserId(QAEngineStore, answerRequestDetails)
 
 	if err != nil {
 		// Error fetching the id of the user
@@ -186,18 +345,26 @@ func AddAnswer(response http.ResponseWriter, request *http.Request, QAEngineData
 		return
 	} else {
 		// Id present in result variable
-		result, err = addAnswerToDatabase(QAEngineDatabase, &answerRequestDetails, result)
+		answer, err := addAnswerToDatabase(QAEngineStore, answerRequestDetails, result)
 
-		if err != nil {
+		if err == errQuestionClosed || err == errQuestionLocked {
+			response.WriteHeader(http.StatusForbidden)
 			json.NewEncoder(response).Encode(Result{
 				Err:     true,
 				Message: err.Error(),
 			})
 			return
-		} else {
+		} else if err != nil {
 			json.NewEncoder(response).Encode(Result{
+				Err:     true,
+				Message: err.Error(),
+			})
+			return
+		} else {
+			json.NewEncoder(response).Encode(ResultAnswer{
 				Err:     false,
-				Message: result,
+				Message: "Added the answer to the database",
+				Data:    answer,
 			})
 			return
 		}
@@ -205,19 +372,16 @@ func AddAnswer(response http.ResponseWriter, request *http.Request, QAEngineData
 
 }
 
-func AddUpVoteToQuestion(response http.ResponseWriter, request *http.Request, QAEngineDatabase *mongo.Database) {
+func AddUpVoteToQuestion(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
 
-	// 1. Get the Username and email of the person who posted the question
-	// 2. Get the title and content of the question to be voted
-	// 3. Get the username and email of the person who is casting the vote
-	// 4. Get the type of the vote (upvote or downvote)
-	// 5. Check if the person voting has already voted the question or not
-	// 6 Update the vote count in the question document of the collection if he has not voted
-	// 7. Add the vote or downvote to the user document of the person who voted if not voted
+	// 1. Get the id, or the username and title, of the question to be voted
+	// 2. Get the username and email of the person who is casting the vote from the token claims
+	// 3. Get the type of the vote (upvote, downvote or none to retract the vote)
+	// 4. Move the vote of the user to the new type and adjust the vote count of the question
 
-	// Steps 1, 2, 3
+	// Steps 1, 2
 
-	err := middlewares.VerifyRequest(response, request)
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
 
 	// Unauthorized access
 	if err != nil {
@@ -232,235 +396,68 @@ func AddUpVoteToQuestion(response http.ResponseWriter, request *http.Request, QA
 	var questionDetails UpVoteRequestQuestion
 
 	json.NewDecoder(request.Body).Decode(&questionDetails)
+	questionDetails.VoteUsername = claims.Username
+	questionDetails.VoteEmail = claims.Email
 
-	// Step 4. Checking the type of the vote
-	// Add +1 vote to the question vote count
-	// Step 5 Check if the vote is already casted by the user
-	var voteDoc model.Votes
-	err = QAEngineDatabase.Collection("votes").FindOne(context.TODO(), bson.M{
-		"username": questionDetails.VoteUsername,
-		"email":    questionDetails.VoteEmail,
-	}).Decode(&voteDoc)
-
-	// Check if the document was found or not
-	if err == mongo.ErrNoDocuments {
-		// No document found
-		// This will happen one time only as the user hasnt voted for a question before
-		// Create a new vote document and insert it
-		error := addNewVoteToUserVoteCollection(QAEngineDatabase, &questionDetails, &voteDoc)
-		if error != nil {
-			json.NewEncoder(response).Encode(Result{Err: true, Message: error.Error()})
-			return
-		} else {
-			json.NewEncoder(response).Encode(Result{Err: false, Message: "Upvote Successful"})
-			return
-		}
-	} else {
-		// Check in the upvotes array if the user has cast the vote or not
-		if questionDetails.VoteType == "upvote" {
-			for _, element := range voteDoc.Upvotes {
-				if element.Title == questionDetails.Title {
-					// User has already cast the vote
-					json.NewEncoder(response).Encode(Result{Err: true, Message: "User already cast the upvote respond to the request "})
-					return
-				}
-			}
-		} else if questionDetails.VoteType == "downvote" {
-			for _, element := range voteDoc.Downvotes {
-				if element.Title == questionDetails.Title {
-					// User has already cast the vote
-					json.NewEncoder(response).Encode(Result{Err: true, Message: "User already cast the downvote respond to the request "})
-					return
-				}
-			}
-		}
-
-		// End of loop user hasnt casted this is a new vote
-		// update
-		filter := bson.M{
-			"username": questionDetails.Username,
-			"title":    questionDetails.Title,
-			"content":  questionDetails.Content,
-		}
-		voteCountType := 1
-
-		if questionDetails.VoteType == "upvote" {
-			voteCountType = 1
-		} else {
-			voteCountType = -1
-		}
-
-		update := bson.M{
-			"$inc": bson.M{
-				"votes": voteCountType,
-			},
-		}
-
-		// Step 6
-		result, _ := QAEngineDatabase.Collection("questions").UpdateOne(context.TODO(), filter, update)
-
-		// Check the error and send the response accordingly
-
-		if result.MatchedCount == 0 {
-			// No matching documnets with the filter provided
-			json.NewEncoder(response).Encode(Result{Err: true, Message: "Failed to vote for the question"})
-			return
-		} else {
-			// Else updated vote count
-
-			// Now add the vote document to user collection who casted the vote
-			filter = bson.M{
-				"username": questionDetails.VoteUsername,
-				"email":    questionDetails.Email,
-			}
-			var votes []model.VoteDoc
-			if questionDetails.VoteType == "upvote" {
-				votes = voteDoc.Upvotes
-			} else {
-				votes = voteDoc.Downvotes
-			}
-
-			votes = append(votes, model.VoteDoc{
-				Title: questionDetails.Title,
-			})
-
-			if questionDetails.VoteType == "upvote" {
-				update = bson.M{
-					"$set": bson.M{
-						"upvotes": votes,
-					},
-				}
-			} else {
-				update = bson.M{
-					"$set": bson.M{
-						"downvotes": votes,
-					},
-				}
-			}
-			// Step 7
-			_, err = QAEngineDatabase.Collection("votes").UpdateOne(context.TODO(), filter, update)
-			if err != nil {
-				// Error updating the document
-				json.NewEncoder(response).Encode(Result{Err: true, Message: "Error updating the document"})
-				return
-			} else {
-				// Success
-				json.NewEncoder(response).Encode(Result{Err: false, Message: "Successfully added the new vote document to the casters collection"})
-				return
-			}
-
-		}
-
-	}
-
-}
-
-func addNewVoteToUserVoteCollection(QAEngineDatabase *mongo.Database, questionDetails *UpVoteRequestQuestion, voteDoc *model.Votes) error {
-	if questionDetails.VoteType == "upvote" {
-		for _, element := range voteDoc.Upvotes {
-			if element.Title == questionDetails.Title {
-				// User has already cast the vote
-				return errors.New("User already cast the vote respond to the request ")
-
-			}
-		}
-	} else if questionDetails.VoteType == "downvote" {
-		for _, element := range voteDoc.Downvotes {
-			if element.Title == questionDetails.Title {
-				// User has already cast the vote
-				return errors.New("User already cast the vote respond to the request ")
-			}
-		}
+	question, err := findQuestion(QAEngineStore, questionDetails.QuestionID, questionDetails.Username, questionDetails.Title)
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Failed to vote for the question"})
+		return
 	}
-
-	filter := bson.M{
-		"username": questionDetails.Username,
-		"title":    questionDetails.Title,
-		"content":  questionDetails.Content,
+	if !verifyNotLocked(response, question) {
+		return
 	}
 
-	voteCountType := 1
-
-	if questionDetails.VoteType == "upvote" {
-		voteCountType = 1
-	} else {
-		voteCountType = -1
+	// Step 3
+	vote, err := parseVoteType(questionDetails.VoteType)
+	if err != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
 	}
-
-	update := bson.M{
-		"$inc": bson.M{
-			"votes": voteCountType,
-		},
+	if vote == model.VoteDown && !verifyPrivilege(response, request, QAEngineStore, QAEngineConfig, claims, config.PrivilegeDownvote) {
+		return
 	}
 
-	// Step 6
-	result, _ := QAEngineDatabase.Collection("questions").UpdateOne(context.TODO(), filter, update)
-
-	if result.MatchedCount == 0 {
-		// Error incrementing the vote for the question
-		return errors.New("Error incrementing the vote for the question")
-	} else {
-		var votes []model.VoteDoc
-		votes = append(votes, model.VoteDoc{
-			Title: questionDetails.Title,
-		})
-
-		var newVoteDoc model.Votes
-
-		if questionDetails.VoteType == "upvote" {
-			newVoteDoc.Username = questionDetails.VoteUsername
-			newVoteDoc.Email = questionDetails.VoteEmail
-			newVoteDoc.Upvotes = votes
-		} else if questionDetails.VoteType == "downvote" {
-			newVoteDoc.Username = questionDetails.VoteUsername
-			newVoteDoc.Email = questionDetails.VoteEmail
-			newVoteDoc.Downvotes = votes
-		}
-
-		_, err := QAEngineDatabase.Collection("votes").InsertOne(context.TODO(), newVoteDoc)
-		if err != nil {
-			// Error inserting the vote documnet
-			return errors.New("Error inserting the new document")
-		} else {
-			// No error, respond accordingly
-			return nil
-		}
+	// Step 4
+	result, err := castVote(QAEngineStore, QAEngineConfig, questionDetails.VoteUsername, questionDetails.VoteEmail, voteTarget{question: &question}, vote)
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
 	}
 
+	json.NewEncoder(response).Encode(ResultVote{Err: false, Message: "Vote recorded", Data: result})
 }
 
-func getUserId(QAEngineDatabase *mongo.Database, answerRequestDetails *AnswerRequestQuestion) (string, error) {
-	var answerReturn model.UserReturnModel
-	result := QAEngineDatabase.Collection("users").FindOne(context.TODO(), bson.M{
-		"username": answerRequestDetails.AnswerUsername,
-		"email":    answerRequestDetails.AnswerEmail,
-	}).Decode(&answerReturn)
+func getUserId(QAEngineStore *store.Store, answerRequestDetails *AnswerRequestQuestion) (string, error) {
+	answerReturn, result := QAEngineStore.Users.FindByUsernameAndEmail(context.TODO(), answerRequestDetails.AnswerUsername, answerRequestDetails.AnswerEmail)
 
-	if result == mongo.ErrNoDocuments {
+	if result == store.ErrNotFound {
 		return "", errors.New("No document found")
 	} else {
 		return answerReturn.ID.String(), nil
 	}
 }
 
-func addAnswerToDatabase(QAEngineDatabase *mongo.Database, answerRequestDetails *AnswerRequestQuestion, userId string) (string, error) {
+func addAnswerToDatabase(QAEngineStore *store.Store, answerRequestDetails *AnswerRequestQuestion, userId string) (model.Answer, error) {
 	// Step 1 Get the user document of the person who posted the question
 	// Step 2 Make a new Answer document and add it to the answers array of the user who posted the question
 	// Step 3 ??
 	// Step 4 Profit
-	var answerReturn model.Question
 	// Step 1
-	result := QAEngineDatabase.Collection("questions").FindOne(context.TODO(), bson.M{
-		"username": answerRequestDetails.QuestionUsername,
-		"title":    answerRequestDetails.Title,
-	}).Decode(&answerReturn)
+	question, result := findQuestion(QAEngineStore, answerRequestDetails.QuestionID, answerRequestDetails.QuestionUsername, answerRequestDetails.Title)
 
-	if result == mongo.ErrNoDocuments {
+	if result == store.ErrNotFound {
 		// No document found
-		return "", errors.New("No documnet found with the username and email")
+		return model.Answer{}, errors.New("No documnet found with the username and email")
+	} else if result != nil {
+		return model.Answer{}, result
+	} else if err := questionTakesAnswers(question); err != nil {
+		return model.Answer{}, err
 	} else {
 		// Step 2
 		answerModel := model.Answer{
+			ID:         primitive.NewObjectID(),
 			UserID:     userId,
 			Answer:     answerRequestDetails.Answer,
 			Username:   answerRequestDetails.AnswerUsername,
@@ -470,105 +467,30 @@ func addAnswerToDatabase(QAEngineDatabase *mongo.Database, answerRequestDetails
 			DatePosted: time.Now(),
 		}
 
-		answersArr := answerReturn.Answers
-		answersArr = append(answersArr, answerModel)
-		// Update the array in the database
-
-		filter := bson.M{
-			"username": answerRequestDetails.QuestionUsername,
-			"title":    answerRequestDetails.Title,
-		}
-
-		update := bson.M{
-			"$set": bson.M{
-				"answers": answersArr,
-			},
-		}
-
-		result, _ := QAEngineDatabase.Collection("questions").UpdateOne(context.TODO(), filter, update)
+		// Append the answer to the array in the database
+		err := QAEngineStore.Questions.AddAnswer(context.TODO(), question.ID, answerModel)
 
-		if result.MatchedCount == 0 {
+		if err != nil {
 			// Failed to update document
-			return "", errors.New("Failed to add the answer to the database")
+			return model.Answer{}, errors.New("Failed to add the answer to the database")
 		} else {
 			// Updated successfully
-			return "Added the answer to the database", nil
+			return answerModel, nil
 		}
 	}
 }
 
-// Gets all questions in the order they were posted
-func GetAllQuestions(response http.ResponseWriter, request *http.Request, QAEngineDatabase *mongo.Database) {
-	var questions []model.Question
-
-	cursor, err := QAEngineDatabase.Collection("questions").Find(context.TODO(), bson.D{})
-	defer cursor.Close(context.TODO())
-	if err != nil {
-		// Error fetching all the questions
-		json.NewEncoder(response).Encode(Result{
-			Err:     false,
-			Message: err.Error(),
-		})
-		return
-	} else {
-		for cursor.Next(context.TODO()) {
-			var question model.Question
-			cursor.Decode(&question)
-
-			questions = append(questions, question)
-		}
-
-		json.NewEncoder(response).Encode(ResultSuccess{
-			Err:     false,
-			Message: "Successfully fetched all questions",
-			Data:    questions,
-		})
-		return
-	}
-
+// Lists the questions in the order they were asked, one page at a time
+func GetAllQuestions(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	listQuestions(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig, store.SortNewest, false)
 }
 
-func GetAllQuestionsByOrder(response http.ResponseWriter, request *http.Request, QAEngineDatabase *mongo.Database) {
-	query := request.URL.Query()
-
-	sort, present := query["sort"]
-
-	if !present || len(sort) == 0 {
-		fmt.Println("sort not present")
-	} else {
-
-		if sort[0] == "top" {
-			var questions []model.Question
-
-			cursor, err := QAEngineDatabase.Collection("questions").Find(context.TODO(), bson.M{
-				"$sort": bson.M{
-					"votes": 1,
-				},
-			})
-			defer cursor.Close(context.TODO())
-			if err != nil {
-				// Error fetching all the questions
-				json.NewEncoder(response).Encode(Result{
-					Err:     false,
-					Message: err.Error(),
-				})
-				return
-			} else {
-				for cursor.Next(context.TODO()) {
-					var question model.Question
-					cursor.Decode(&question)
-
-					questions = append(questions, question)
-				}
-
-				json.NewEncoder(response).Encode(ResultSuccess{
-					Err:     false,
-					Message: "Successfully fetched all questions",
-					Data:    questions,
-				})
-				return
-			}
-		}
-
+// Lists the questions in the order named by the sort parameter, top by default.
+// Every order starts with the highest, newest or latest question unless order=asc is given
+func GetAllQuestionsByOrder(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	sort, found := sortFromRequest(response, request, store.SortTop)
+	if !found {
+		return
 	}
+	listQuestions(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig, sort, true)
 }
@@ -0,0 +1,273 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errQuestionClosed = errors.New("The question is closed and takes no new answers")
+	errQuestionLocked = errors.New("The question is locked")
+)
+
+// SetQuestionStateRequest moves a question to another state. Closing needs a reason, and closing
+// as a duplicate needs the id of the question it duplicates
+type SetQuestionStateRequest struct {
+	State       string `json:"state"`
+	Reason      string `json:"reason"`
+	DuplicateOf string `json:"duplicateof"`
+}
+
+// Reports why the question takes no new answers, nil when it does
+func questionTakesAnswers(question model.Question) error {
+	switch question.CurrentState() {
+	case model.QuestionClosed:
+		return errQuestionClosed
+	case model.QuestionLocked:
+		return errQuestionLocked
+	}
+	return nil
+}
+
+// Writes the error response and returns false when the question is locked. Locked questions take
+// no answers, votes, edits or accepted answers until a moderator unlocks them
+func verifyNotLocked(response http.ResponseWriter, question model.Question) bool {
+	if question.CurrentState() == model.QuestionLocked {
+		response.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: errQuestionLocked.Error()})
+		return false
+	}
+	return true
+}
+
+// Reads the new status of the question from the request, writing the error response when it is invalid
+func statusFromRequest(response http.ResponseWriter, QAEngineStore *store.Store, question model.Question, stateRequest SetQuestionStateRequest) (model.QuestionStatus, bool) {
+	status := model.QuestionStatus{State: stateRequest.State}
+	if !containsString(model.QuestionStates, status.State) {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("state", status.State, "Unknown state "+strconv.Quote(status.State), model.QuestionStates...))
+		return status, false
+	}
+	if status.State != model.QuestionClosed {
+		return status, true
+	}
+
+	status.CloseReason = stateRequest.Reason
+	if !containsString(model.CloseReasons, status.CloseReason) {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("reason", status.CloseReason, "Closing a question needs one of the close reasons", model.CloseReasons...))
+		return status, false
+	}
+	if status.CloseReason != model.CloseDuplicate {
+		return status, true
+	}
+
+	original, err := findQuestionByID(QAEngineStore, stateRequest.DuplicateOf, false)
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The duplicated question was not found"})
+		return status, false
+	} else if err != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("duplicateof", stateRequest.DuplicateOf, "Closing as a duplicate needs the id of the duplicated question"))
+		return status, false
+	}
+	if original.ID == question.ID {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("duplicateof", stateRequest.DuplicateOf, "A question cannot be a duplicate of itself"))
+		return status, false
+	}
+	status.DuplicateOf = &original.ID
+	return status, true
+}
+
+// Reports whether both statuses close the question as a duplicate of the same question, or neither does
+func sameDuplicate(a model.QuestionStatus, b model.QuestionStatus) bool {
+	if a.DuplicateOf == nil || b.DuplicateOf == nil {
+		return a.DuplicateOf == b.DuplicateOf
+	}
+	return *a.DuplicateOf == *b.DuplicateOf
+}
+
+// Opens, closes, locks or deletes the question in the path. The author can close, reopen and delete
+// the question unless it is locked, moderators can do anything, and they alone lock and unlock
+func SetQuestionState(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	moderator := QAEngineConfig.IsModerator(claims.Username)
+	question, err := findQuestionByID(QAEngineStore, mux.Vars(request)["id"], moderator)
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Question not found"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	var stateRequest SetQuestionStateRequest
+	json.NewDecoder(request.Body).Decode(&stateRequest)
+	defer request.Body.Close()
+
+	status, valid := statusFromRequest(response, QAEngineStore, question, stateRequest)
+	if !valid {
+		return
+	}
+
+	if !moderator {
+		if question.Username != claims.Username {
+			response.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: "Only the author or a moderator can change the state of the question"})
+			return
+		}
+		if status.State == model.QuestionLocked {
+			response.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: "Only moderators can lock a question"})
+			return
+		}
+		if !verifyNotLocked(response, question) {
+			return
+		}
+	}
+
+	if status.State == question.CurrentState() && status.CloseReason == question.Status.CloseReason && sameDuplicate(status, question.Status) {
+		response.WriteHeader(http.StatusConflict)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The question is already " + status.State})
+		return
+	}
+
+	status.ChangedBy = claims.Username
+	status.ChangedAt = time.Now()
+	question, err = QAEngineStore.Questions.SetStatus(context.TODO(), question.ID, status)
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Question not found"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	presentQuestion(&question, moderator)
+	if !attachReputation(response, QAEngineStore, &question) {
+		return
+	}
+	json.NewEncoder(response).Encode(ResultQuestion{Err: false, Message: "The question is now " + status.State, Data: question})
+}
+
+// The reason most close votes agree on, duplicates of different questions counting apart.
+// Ties go to the reason voted first
+func closeVoteOutcome(votes []model.CloseVote) model.CloseVote {
+	counts := map[string]int{}
+	key := func(vote model.CloseVote) string {
+		if vote.DuplicateOf != nil {
+			return vote.Reason + "/" + vote.DuplicateOf.Hex()
+		}
+		return vote.Reason
+	}
+
+	var outcome model.CloseVote
+	for _, vote := range votes {
+		counts[key(vote)]++
+	}
+	for _, vote := range votes {
+		if outcome.Reason == "" || counts[key(vote)] > counts[key(outcome)] {
+			outcome = vote
+		}
+	}
+	return outcome
+}
+
+// Votes to close the question in the path with the reason in the request. Voting needs the close-vote
+// privilege, and the question closes once it has the configured number of votes, for the reason most votes agree on
+func VoteToClose(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyPrivilege(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig, config.PrivilegeCloseVote)
+	if err != nil {
+		json.NewEncoder(response).Encode(refusal(err))
+		return
+	}
+
+	question, found := questionFromPath(response, request, QAEngineStore)
+	if !found {
+		return
+	}
+	if question.CurrentState() != model.QuestionOpen {
+		response.WriteHeader(http.StatusConflict)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Only open questions take close votes"})
+		return
+	}
+
+	var stateRequest SetQuestionStateRequest
+	json.NewDecoder(request.Body).Decode(&stateRequest)
+	defer request.Body.Close()
+
+	stateRequest.State = model.QuestionClosed
+	status, valid := statusFromRequest(response, QAEngineStore, question, stateRequest)
+	if !valid {
+		return
+	}
+
+	vote := model.CloseVote{Username: claims.Username, Reason: status.CloseReason, DuplicateOf: status.DuplicateOf, CreatedAt: time.Now()}
+	question, err = QAEngineStore.Questions.AddCloseVote(context.TODO(), question.ID, vote)
+	if err == store.ErrDuplicate {
+		response.WriteHeader(http.StatusConflict)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "You already voted to close the question"})
+		return
+	} else if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusConflict)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Only open questions take close votes"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	message := "Close vote recorded, " + strconv.Itoa(len(question.CloseVotes)) + " of " + strconv.Itoa(QAEngineConfig.CloseVotesNeeded)
+	if len(question.CloseVotes) >= QAEngineConfig.CloseVotesNeeded {
+		outcome := closeVoteOutcome(question.CloseVotes)
+		status = model.QuestionStatus{
+			State:       model.QuestionClosed,
+			CloseReason: outcome.Reason,
+			DuplicateOf: outcome.DuplicateOf,
+			ChangedBy:   claims.Username,
+			ChangedAt:   time.Now(),
+		}
+		question, err = QAEngineStore.Questions.SetStatus(context.TODO(), question.ID, status)
+		if err != nil {
+			response.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+			return
+		}
+		message = "The question is now closed"
+	}
+
+	presentQuestion(&question, false)
+	if !attachReputation(response, QAEngineStore, &question) {
+		return
+	}
+	json.NewEncoder(response).Encode(ResultQuestion{Err: false, Message: message, Data: question})
+}
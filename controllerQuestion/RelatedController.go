@@ -0,0 +1,102 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"example.org/model"
+	"example.org/store"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RelatedQuestion is the summary of a question shown next to another one
+type RelatedQuestion struct {
+	QuestionID  string   `json:"questionid"`
+	Title       string   `json:"title"`
+	Tags        []string `json:"tags"`
+	Votes       int      `json:"votes"`
+	AnswerCount int      `json:"answercount"`
+	// How close the question is, between 0 and 1. Linked questions have none
+	Score float64 `json:"score,omitempty"`
+}
+
+type RelatedQuestions struct {
+	// Questions sharing tags and wording with the question
+	Related []RelatedQuestion `json:"related"`
+	// Questions the question or its answers link to, and questions linking to it
+	Linked []RelatedQuestion `json:"linked"`
+}
+
+type ResultRelated struct {
+	Err     bool             `json:"error"`
+	Message string           `json:"message"`
+	Data    RelatedQuestions `json:"data"`
+}
+
+func relatedQuestion(QAEngineStore *store.Store, id primitive.ObjectID, score float64) (*RelatedQuestion, error) {
+	question, err := QAEngineStore.Questions.FindByID(context.TODO(), id)
+	if err == store.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	// Deleted questions are left out like the missing ones
+	if question.CurrentState() == model.QuestionDeleted {
+		return nil, nil
+	}
+	return &RelatedQuestion{
+		QuestionID:  question.ID.Hex(),
+		Title:       question.Title,
+		Tags:        question.Tags,
+		Votes:       question.Votes,
+		AnswerCount: question.AnswerCount,
+		Score:       score,
+	}, nil
+}
+
+// Lists the questions related to the question in the path and the ones linked to or from it
+func GetRelatedQuestions(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(request)["id"])
+	if err != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Invalid question id"})
+		return
+	}
+
+	related, found := QAEngineStore.Search.Related(id)
+	if !found {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Question not found"})
+		return
+	}
+
+	data := RelatedQuestions{Related: []RelatedQuestion{}, Linked: []RelatedQuestion{}}
+	for _, hit := range related.Related {
+		question, err := relatedQuestion(QAEngineStore, hit.ID, hit.Score)
+		if err != nil {
+			response.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+			return
+		}
+		if question != nil {
+			data.Related = append(data.Related, *question)
+		}
+	}
+	for _, linked := range related.Linked {
+		question, err := relatedQuestion(QAEngineStore, linked, 0)
+		if err != nil {
+			response.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+			return
+		}
+		if question != nil {
+			data.Linked = append(data.Linked, *question)
+		}
+	}
+
+	json.NewEncoder(response).Encode(ResultRelated{Err: false, Message: "Successfully fetched the related questions", Data: data})
+}
@@ -0,0 +1,173 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"example.org/config"
+	"example.org/model"
+	"example.org/reputation"
+	"example.org/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The reason a vote on the target changes the reputation of its author, empty for no vote
+func voteReason(target voteTarget, vote string) string {
+	switch {
+	case vote == model.VoteUp && target.answerID.IsZero():
+		return model.ReputationQuestionUpvoted
+	case vote == model.VoteDown && target.answerID.IsZero():
+		return model.ReputationQuestionDownvoted
+	case vote == model.VoteUp:
+		return model.ReputationAnswerUpvoted
+	case vote == model.VoteDown:
+		return model.ReputationAnswerDownvoted
+	}
+	return ""
+}
+
+// Adds an entry for the reason to the ledger of the user, for what the actor did on the post.
+// Users earn nothing from what they do on their own posts
+func awardReputation(ctx context.Context, QAEngineStore *store.Store, QAEngineConfig *config.Config, username string, reason string, actor string, questionID primitive.ObjectID, answerID primitive.ObjectID) error {
+	if username == actor {
+		return nil
+	}
+
+	entry := model.ReputationEntry{
+		Username:   username,
+		Reason:     reason,
+		Actor:      actor,
+		QuestionID: questionID,
+		AnswerID:   answerID,
+		CreatedAt:  time.Now(),
+	}
+	earned := 0
+	if reputation.Capped(reason) {
+		var err error
+		earned, err = QAEngineStore.Reputation.Sum(ctx, username, reputation.CappedReasons, reputation.Day(entry.CreatedAt))
+		if err != nil {
+			return err
+		}
+	}
+	entry.Points = reputation.Award(reason, earned, QAEngineConfig.ReputationDailyCap)
+
+	if err := QAEngineStore.Reputation.Insert(ctx, &entry); err != nil {
+		return err
+	}
+	return QAEngineStore.Users.AddReputation(ctx, username, entry.Points)
+}
+
+// Takes back the points of the latest entry of the user for the reason caused by the actor on the post,
+// unless they were already taken back
+func undoReputation(ctx context.Context, QAEngineStore *store.Store, username string, reason string, actor string, questionID primitive.ObjectID, answerID primitive.ObjectID) error {
+	last, err := QAEngineStore.Reputation.Last(ctx, username, reason, actor, questionID, answerID)
+	if err == store.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if last.Undoes != nil {
+		return nil
+	}
+
+	entry := model.ReputationEntry{
+		Username:   username,
+		Reason:     reason,
+		Actor:      actor,
+		QuestionID: questionID,
+		AnswerID:   answerID,
+		Points:     -last.Points,
+		Undoes:     &last.ID,
+		CreatedAt:  time.Now(),
+	}
+	if err = QAEngineStore.Reputation.Insert(ctx, &entry); err != nil {
+		return err
+	}
+	return QAEngineStore.Users.AddReputation(ctx, username, entry.Points)
+}
+
+// Awards the user again for the reason caused by the actor on the post, when the latest entry for it
+// was taken back. Posts whose points were never taken back are not paid twice
+func restoreReputation(ctx context.Context, QAEngineStore *store.Store, QAEngineConfig *config.Config, username string, reason string, actor string, questionID primitive.ObjectID, answerID primitive.ObjectID) error {
+	last, err := QAEngineStore.Reputation.Last(ctx, username, reason, actor, questionID, answerID)
+	if err == store.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if last.Undoes == nil {
+		return nil
+	}
+	return awardReputation(ctx, QAEngineStore, QAEngineConfig, username, reason, actor, questionID, answerID)
+}
+
+// Moves the reputation the voter gave the author of the target from the previous vote to the new one
+func moveVoteReputation(ctx context.Context, QAEngineStore *store.Store, QAEngineConfig *config.Config, voterUsername string, target voteTarget, previousVote string, newVote string) error {
+	author := target.author()
+	if reason := voteReason(target, previousVote); reason != "" {
+		if err := undoReputation(ctx, QAEngineStore, author, reason, voterUsername, target.question.ID, target.answerID); err != nil {
+			return err
+		}
+	}
+	if reason := voteReason(target, newVote); reason != "" {
+		return awardReputation(ctx, QAEngineStore, QAEngineConfig, author, reason, voterUsername, target.question.ID, target.answerID)
+	}
+	return nil
+}
+
+// Takes back the reputation the votes held on the answer earned its author or, with restore, awards
+// again what was taken back. Deleted answers earn nothing from their votes, which are kept for when the
+// answer is undeleted
+func moveAnswerVotesReputation(ctx context.Context, QAEngineStore *store.Store, QAEngineConfig *config.Config, question model.Question, answer model.Answer, restore bool) error {
+	votes, err := QAEngineStore.Votes.List(ctx, question.ID, answer.ID)
+	if err != nil {
+		return err
+	}
+
+	target := voteTarget{question: &question, answerID: answer.ID}
+	for _, vote := range votes {
+		reason := voteReason(target, vote.Vote)
+		if reason == "" {
+			continue
+		}
+		if restore {
+			err = restoreReputation(ctx, QAEngineStore, QAEngineConfig, answer.Username, reason, vote.Key.Username, question.ID, answer.ID)
+		} else {
+			err = undoReputation(ctx, QAEngineStore, answer.Username, reason, vote.Key.Username, question.ID, answer.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fills in the reputation of the authors of the questions and of their answers, writing the error
+// response when it cannot be read
+func attachReputation(response http.ResponseWriter, QAEngineStore *store.Store, questions ...*model.Question) bool {
+	usernames := []string{}
+	for _, question := range questions {
+		usernames = append(usernames, question.Username, question.SelectedAnswer.Username)
+		for _, answer := range question.Answers {
+			usernames = append(usernames, answer.Username)
+		}
+	}
+
+	reputations, err := QAEngineStore.Users.Reputations(context.TODO(), usernames)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return false
+	}
+
+	for _, question := range questions {
+		question.Reputation = reputations[question.Username]
+		question.SelectedAnswer.Reputation = reputations[question.SelectedAnswer.Username]
+		for i := range question.Answers {
+			question.Answers[i].Reputation = reputations[question.Answers[i].Username]
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package controllerQuestion
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+	"github.com/gorilla/mux"
+)
+
+// ReviewQueueClose holds the open questions that have close votes waiting for more
+const ReviewQueueClose = "close"
+
+var ReviewQueues = []string{ReviewQueueClose}
+
+// Lists the questions waiting in the review queue in the path, newest first. The listing parameters
+// order, limit, cursor, since, until, tags and match apply. Only users with the review privilege can see the queues
+func GetReviewQueue(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	_, err := middlewares.VerifyPrivilege(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig, config.PrivilegeReview)
+	if err != nil {
+		json.NewEncoder(response).Encode(refusal(err))
+		return
+	}
+
+	queue := mux.Vars(request)["queue"]
+	if !containsString(ReviewQueues, queue) {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(invalidParameter("queue", queue, "Unknown review queue "+strconv.Quote(queue), ReviewQueues...))
+		return
+	}
+
+	query, invalid := parseQuestionQuery(request, store.SortNewest, true)
+	if invalid != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalid)
+		return
+	}
+	query.States = []string{model.QuestionOpen}
+	query.CloseVoted = true
+	writeQuestionPage(response, QAEngineStore, query)
+}
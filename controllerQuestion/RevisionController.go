@@ -0,0 +1,514 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.org/config"
+	"example.org/diff"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxRevisionCommentLength = 300
+
+// EditQuestionRequest changes the fields that are given and keeps the others as they are
+type EditQuestionRequest struct {
+	Title   *string   `json:"title"`
+	Content *string   `json:"content"`
+	Tags    *[]string `json:"tags"`
+	// Why the question was edited, shown in its history
+	Comment string `json:"comment"`
+}
+
+type RollbackRequest struct {
+	Comment string `json:"comment"`
+}
+
+// RevisionDiff is what changed between two revisions of a post
+type RevisionDiff struct {
+	From        int         `json:"from"`
+	To          int         `json:"to"`
+	Title       []diff.Line `json:"title,omitempty"`
+	Content     []diff.Line `json:"content"`
+	TagsAdded   []string    `json:"tagsadded,omitempty"`
+	TagsRemoved []string    `json:"tagsremoved,omitempty"`
+}
+
+// RevisionDetail is a revision along with what it changed from the one before it, none for revision 1
+type RevisionDetail struct {
+	model.Revision
+	Diff *RevisionDiff `json:"diff"`
+}
+
+type ResultRevisions struct {
+	Err     bool             `json:"error"`
+	Message string           `json:"message"`
+	Data    []model.Revision `json:"data"`
+}
+
+type ResultRevision struct {
+	Err     bool           `json:"error"`
+	Message string         `json:"message"`
+	Data    RevisionDetail `json:"data"`
+}
+
+type ResultRevisionDiff struct {
+	Err     bool         `json:"error"`
+	Message string       `json:"message"`
+	Data    RevisionDiff `json:"data"`
+}
+
+func diffRevisions(from model.Revision, to model.Revision) RevisionDiff {
+	revisionDiff := RevisionDiff{
+		From:        from.Number,
+		To:          to.Number,
+		Content:     diff.Lines(from.Content, to.Content),
+		TagsAdded:   []string{},
+		TagsRemoved: []string{},
+	}
+	if from.Title != "" || to.Title != "" {
+		revisionDiff.Title = diff.Words(from.Title, to.Title)
+	}
+	for _, tag := range to.Tags {
+		if !containsString(from.Tags, tag) {
+			revisionDiff.TagsAdded = append(revisionDiff.TagsAdded, tag)
+		}
+	}
+	for _, tag := range from.Tags {
+		if !containsString(to.Tags, tag) {
+			revisionDiff.TagsRemoved = append(revisionDiff.TagsRemoved, tag)
+		}
+	}
+	return revisionDiff
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// The first revision of a question, the question as it was asked. It shares the ID of the question
+func firstQuestionRevision(question model.Question) model.Revision {
+	createdAt := question.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = question.ID.Timestamp()
+	}
+	return model.Revision{
+		ID:         question.ID,
+		QuestionID: question.ID,
+		Number:     1,
+		Editor:     question.Username,
+		CreatedAt:  createdAt,
+		Changed:    []string{model.RevisionTitle, model.RevisionContent, model.RevisionTags},
+		Title:      question.Title,
+		Content:    question.Content,
+		Tags:       append([]string{}, question.Tags...),
+	}
+}
+
+// Returns the revisions of the question, oldest first. A question that was never edited has no stored
+// revisions, its first revision is then made up from the question itself
+func questionRevisions(QAEngineStore *store.Store, question model.Question) ([]model.Revision, error) {
+	revisions, err := QAEngineStore.Revisions.List(context.TODO(), question.ID, primitive.NilObjectID)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		revisions = []model.Revision{firstQuestionRevision(question)}
+	}
+	return revisions, nil
+}
+
+// Finds the question in the path, writing the error response when there is none
+func questionFromPath(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) (model.Question, bool) {
+	question, err := findQuestion(QAEngineStore, mux.Vars(request)["id"], "", "")
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Question not found"})
+		return question, false
+	} else if err != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return question, false
+	}
+	return question, true
+}
+
+// Checks that the logged in user can edit the question in the path, writing the error response when not.
+// Users other than its author need the edit privilege
+func verifyQuestionEditor(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) (*model.Claims, model.Question, bool) {
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return nil, model.Question{}, false
+	}
+
+	question, found := questionFromPath(response, request, QAEngineStore)
+	if !found || !verifyNotLocked(response, question) {
+		return nil, question, false
+	}
+	if question.Username != claims.Username && !verifyPrivilege(response, request, QAEngineStore, QAEngineConfig, claims, config.PrivilegeEdit) {
+		return nil, question, false
+	}
+	return claims, question, true
+}
+
+// Finds the revision numbered by the path parameter, writing the error response when there is none
+func revisionFromPath(response http.ResponseWriter, request *http.Request, revisions []model.Revision, parameter string) (model.Revision, bool) {
+	value := mux.Vars(request)[parameter]
+	if value == "" {
+		value = request.URL.Query().Get(parameter)
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter(parameter, value, "Revisions are numbered from 1"))
+		return model.Revision{}, false
+	}
+	if number > len(revisions) {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Revision not found"})
+		return model.Revision{}, false
+	}
+	return revisions[number-1], true
+}
+
+// Stores revision as the one following latest. Revision numbers are unique per post, so of two
+// concurrent edits only the first one lands and the other gets store.ErrDuplicate
+func storeRevision(QAEngineStore *store.Store, latest model.Revision, revision *model.Revision) error {
+	// The post as it was first written is stored with the first edit
+	if latest.Number == 1 {
+		err := QAEngineStore.Revisions.Insert(context.TODO(), &latest)
+		if err != nil && err != store.ErrDuplicate {
+			return err
+		}
+	}
+
+	revision.Number = latest.Number + 1
+	revision.CreatedAt = time.Now()
+	return QAEngineStore.Revisions.Insert(context.TODO(), revision)
+}
+
+// Stores revision as the one following latest, writing the error response when it cannot be.
+// The edit is applied after, and dropRevision takes the revision back when that fails
+func insertRevision(response http.ResponseWriter, QAEngineStore *store.Store, latest model.Revision, revision *model.Revision) bool {
+	err := storeRevision(QAEngineStore, latest, revision)
+	if err == store.ErrDuplicate {
+		response.WriteHeader(http.StatusConflict)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The post was edited in the meantime, reload it and try again"})
+		return false
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return false
+	}
+	return true
+}
+
+// Takes back the revision of an edit that could not be applied, so the history only holds edits that
+// landed. The edit already failed, an error here leaves the revision behind and nothing more
+func dropRevision(QAEngineStore *store.Store, revision model.Revision) {
+	QAEngineStore.Revisions.Delete(context.TODO(), revision.ID)
+}
+
+// Records the tags a merge filed the question under as a revision of the moderator who merged them,
+// so the next edit is not credited with the change. question is the question as it was before the merge
+func saveRetagRevision(QAEngineStore *store.Store, question model.Question, editor string, comment string) error {
+	revisions, err := questionRevisions(QAEngineStore, question)
+	if err != nil {
+		return err
+	}
+	latest := revisions[len(revisions)-1]
+	retagged, err := QAEngineStore.Questions.FindByID(context.TODO(), question.ID)
+	if err != nil {
+		return err
+	}
+	if strings.Join(retagged.Tags, ",") == strings.Join(latest.Tags, ",") {
+		return nil
+	}
+
+	revision := model.Revision{
+		QuestionID: question.ID,
+		Editor:     editor,
+		Comment:    comment,
+		Changed:    []string{model.RevisionTags},
+		Title:      retagged.Title,
+		Content:    retagged.Content,
+		Tags:       retagged.Tags,
+	}
+	err = storeRevision(QAEngineStore, latest, &revision)
+	// An edit in the meantime already recorded the new tags
+	if err == store.ErrDuplicate {
+		return nil
+	}
+	return err
+}
+
+// Stores the new state of the question as its next revision, then applies it to the question
+func saveQuestionRevision(response http.ResponseWriter, QAEngineStore *store.Store, question model.Question, revision model.Revision) (model.Question, bool) {
+	revisions, err := questionRevisions(QAEngineStore, question)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return question, false
+	}
+	latest := revisions[len(revisions)-1]
+	if revision.Tags == nil {
+		revision.Tags = []string{}
+	}
+
+	revision.Changed = []string{}
+	if revision.Title != latest.Title {
+		revision.Changed = append(revision.Changed, model.RevisionTitle)
+	}
+	if revision.Content != latest.Content {
+		revision.Changed = append(revision.Changed, model.RevisionContent)
+	}
+	if strings.Join(revision.Tags, ",") != strings.Join(latest.Tags, ",") {
+		revision.Changed = append(revision.Changed, model.RevisionTags)
+	}
+	if len(revision.Changed) == 0 {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The edit changes nothing"})
+		return question, false
+	}
+
+	// Titles stay unique, as when asking
+	if revision.Title != question.Title {
+		other, err := QAEngineStore.Questions.FindByTitle(context.TODO(), revision.Title)
+		if err == nil && other.ID != question.ID {
+			response.WriteHeader(http.StatusConflict)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: "Question with that title already found in the database"})
+			return question, false
+		}
+	}
+
+	revision.QuestionID = question.ID
+	if !insertRevision(response, QAEngineStore, latest, &revision) {
+		return question, false
+	}
+
+	err = QAEngineStore.Tags.EnsureTags(context.TODO(), revision.Tags)
+	if err == nil {
+		err = QAEngineStore.Questions.Edit(context.TODO(), question.ID, revision.Title, revision.Content, revision.Tags)
+	}
+	if err != nil {
+		dropRevision(QAEngineStore, revision)
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return question, false
+	}
+
+	question, err = QAEngineStore.Questions.FindByID(context.TODO(), question.ID)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return question, false
+	}
+	return question, true
+}
+
+// Edits the title, content or tags of the question in the path. Its author and the users with the edit privilege can edit it
+func EditQuestion(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, question, authorized := verifyQuestionEditor(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	if !authorized {
+		return
+	}
+
+	var editRequest EditQuestionRequest
+	json.NewDecoder(request.Body).Decode(&editRequest)
+	defer request.Body.Close()
+
+	revision := model.Revision{
+		Editor:  claims.Username,
+		Comment: strings.TrimSpace(editRequest.Comment),
+		Title:   question.Title,
+		Content: question.Content,
+		Tags:    question.Tags,
+	}
+	if len(revision.Comment) > maxRevisionCommentLength {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The comment is limited to " + strconv.Itoa(maxRevisionCommentLength) + " characters"})
+		return
+	}
+
+	if editRequest.Title != nil {
+		revision.Title = strings.TrimSpace(*editRequest.Title)
+		if revision.Title == "" {
+			response.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: "The title cannot be empty"})
+			return
+		}
+	}
+	if editRequest.Content != nil {
+		revision.Content = *editRequest.Content
+	}
+	if editRequest.Tags != nil {
+		tags, invalid := normalizeTags(*editRequest.Tags)
+		if invalid != nil {
+			response.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(response).Encode(invalid)
+			return
+		}
+		applied, err := applyTagSynonyms(QAEngineStore, tags)
+		if err != nil {
+			response.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+			return
+		}
+		revision.Tags = applied
+	}
+
+	question, saved := saveQuestionRevision(response, QAEngineStore, question, revision)
+	if !saved {
+		return
+	}
+	presentQuestion(&question, false)
+	if !attachReputation(response, QAEngineStore, &question) {
+		return
+	}
+	json.NewEncoder(response).Encode(ResultQuestion{Err: false, Message: "Question edited", Data: question})
+}
+
+// Lists the revisions of the question in the path, oldest first
+func GetQuestionRevisions(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+
+	question, found := questionFromPath(response, request, QAEngineStore)
+	if !found {
+		return
+	}
+	revisions, err := questionRevisions(QAEngineStore, question)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultRevisions{Err: false, Message: "Successfully fetched the revisions", Data: revisions})
+}
+
+// Returns a revision of the question in the path with what it changed from the previous one
+func GetQuestionRevision(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+
+	question, found := questionFromPath(response, request, QAEngineStore)
+	if !found {
+		return
+	}
+	revisions, err := questionRevisions(QAEngineStore, question)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	revision, found := revisionFromPath(response, request, revisions, "number")
+	if !found {
+		return
+	}
+
+	detail := RevisionDetail{Revision: revision}
+	if revision.Number > 1 {
+		revisionDiff := diffRevisions(revisions[revision.Number-2], revision)
+		detail.Diff = &revisionDiff
+	}
+	json.NewEncoder(response).Encode(ResultRevision{Err: false, Message: "Successfully fetched the revision", Data: detail})
+}
+
+// Diffs the revisions from and to of the question in the path, to defaults to the latest revision
+func DiffQuestionRevisions(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+
+	question, found := questionFromPath(response, request, QAEngineStore)
+	if !found {
+		return
+	}
+	revisions, err := questionRevisions(QAEngineStore, question)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	from, found := revisionFromPath(response, request, revisions, "from")
+	if !found {
+		return
+	}
+	to := revisions[len(revisions)-1]
+	if request.URL.Query().Get("to") != "" {
+		if to, found = revisionFromPath(response, request, revisions, "to"); !found {
+			return
+		}
+	}
+	json.NewEncoder(response).Encode(ResultRevisionDiff{Err: false, Message: "Successfully compared the revisions", Data: diffRevisions(from, to)})
+}
+
+// Restores the question in the path to an earlier revision, recorded as a new revision.
+// Those who can edit the question can roll it back
+func RollbackQuestion(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, question, authorized := verifyQuestionEditor(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	if !authorized {
+		return
+	}
+
+	var rollbackRequest RollbackRequest
+	json.NewDecoder(request.Body).Decode(&rollbackRequest)
+	defer request.Body.Close()
+
+	revisions, err := questionRevisions(QAEngineStore, question)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	target, found := revisionFromPath(response, request, revisions, "number")
+	if !found {
+		return
+	}
+
+	comment := strings.TrimSpace(rollbackRequest.Comment)
+	if comment == "" {
+		comment = "Rolled back to revision " + strconv.Itoa(target.Number)
+	}
+	if len(comment) > maxRevisionCommentLength {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The comment is limited to " + strconv.Itoa(maxRevisionCommentLength) + " characters"})
+		return
+	}
+
+	question, saved := saveQuestionRevision(response, QAEngineStore, question, model.Revision{
+		Editor:     claims.Username,
+		Comment:    comment,
+		RollbackOf: target.Number,
+		Title:      target.Title,
+		Content:    target.Content,
+		Tags:       target.Tags,
+	})
+	if !saved {
+		return
+	}
+	presentQuestion(&question, false)
+	if !attachReputation(response, QAEngineStore, &question) {
+		return
+	}
+	json.NewEncoder(response).Encode(ResultQuestion{Err: false, Message: "Question rolled back to revision " + strconv.Itoa(target.Number), Data: question})
+}
@@ -0,0 +1,63 @@
+package controllerQuestion
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.org/model"
+	"example.org/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A question store whose edits fail
+type failingEdits struct {
+	store.QuestionStore
+}
+
+func (failingEdits) Edit(ctx context.Context, id primitive.ObjectID, title string, content string, tags []string) error {
+	return errors.New("edit failed")
+}
+
+// An edit that cannot be applied leaves no revision behind, and the next edit takes its number
+func TestFailedEditDropsRevision(t *testing.T) {
+	QAEngineStore := store.NewMemoryStore()
+	id := askQuestion(t, QAEngineStore, "parse json", 0)
+	question, err := QAEngineStore.Questions.FindByID(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit := func() (model.Question, *httptest.ResponseRecorder, bool) {
+		recorder := httptest.NewRecorder()
+		revision := model.Revision{Editor: "alice", Title: "parse json in go", Content: question.Content, Tags: question.Tags}
+		edited, saved := saveQuestionRevision(recorder, QAEngineStore, question, revision)
+		return edited, recorder, saved
+	}
+
+	questions := QAEngineStore.Questions
+	QAEngineStore.Questions = failingEdits{questions}
+	if _, recorder, saved := edit(); saved || recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("failed edit: saved %v, status %d", saved, recorder.Code)
+	}
+	revisions, err := questionRevisions(QAEngineStore, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 || revisions[0].Title != "parse json" {
+		t.Fatalf("revisions after a failed edit: %+v, want the question as asked only", revisions)
+	}
+
+	QAEngineStore.Questions = questions
+	edited, recorder, saved := edit()
+	if !saved || edited.Title != "parse json in go" {
+		t.Fatalf("edit: saved %v, status %d, title %q", saved, recorder.Code, edited.Title)
+	}
+	if revisions, err = questionRevisions(QAEngineStore, question); err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[1].Number != 2 || revisions[1].Title != "parse json in go" {
+		t.Errorf("revisions after the edit: %+v", revisions)
+	}
+}
@@ -0,0 +1,142 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"example.org/model"
+	"example.org/search"
+	"example.org/store"
+)
+
+// SearchResult is a question found by a search along with how well it matched
+type SearchResult struct {
+	model.Question
+	Score float64 `json:"score"`
+}
+
+type ResultSearch struct {
+	Err        bool           `json:"error"`
+	Message    string         `json:"message"`
+	Data       []SearchResult `json:"data"`
+	Total      int            `json:"total"`
+	NextCursor string         `json:"next_cursor"`
+	HasMore    bool           `json:"has_more"`
+}
+
+// The cursor of a search is the last hit of the page, its score, votes and ID. The next page starts
+// after it in the ranking, so questions indexed or removed in between do not shift the pages
+func encodeSearchCursor(hit search.Hit) string {
+	data, _ := json.Marshal(hit)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchCursor(value string) (*search.Hit, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var hit search.Hit
+	if err = json.Unmarshal(data, &hit); err != nil || hit.ID.IsZero() {
+		return nil, errInvalidCursor
+	}
+	return &hit, nil
+}
+
+// The position of the first hit after the cursor. Scores move whenever a question is indexed, as the
+// word frequencies they depend on change, so the cursor is looked up by ID. Its score and votes only
+// serve when the question is no longer found, and are then as good as the scores have held
+func searchStart(hits []search.Hit, after search.Hit) int {
+	for i, hit := range hits {
+		if hit.ID == after.ID {
+			return i + 1
+		}
+	}
+	return sort.Search(len(hits), func(i int) bool {
+		return after.Before(hits[i])
+	})
+}
+
+// Searches the titles, contents and answers of the questions, the most relevant first.
+// q takes words, "quoted phrases" and the filters tag:, user:, votes:>N and is:answered
+func SearchQuestions(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+	parameters := request.URL.Query()
+
+	query, err := search.ParseQuery(parameters.Get("q"))
+	if err != nil {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("q", parameters.Get("q"), err.Error()))
+		return
+	}
+	// Tags are searched under the name their questions are filed under
+	for i, tag := range query.Tags {
+		query.Tags[i] = normalizeTag(tag)
+		synonym, err := QAEngineStore.Tags.FindSynonym(context.TODO(), query.Tags[i])
+		if err == nil && synonym.Status == model.SynonymApproved {
+			query.Tags[i] = synonym.Target
+		}
+	}
+
+	limit := defaultPageLimit
+	if value := parameters.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			response.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(response).Encode(invalidParameter("limit", value, "limit must be a number between 1 and "+strconv.Itoa(maxPageLimit)))
+			return
+		}
+	}
+
+	var after *search.Hit
+	if value := parameters.Get("cursor"); value != "" {
+		if after, err = decodeSearchCursor(value); err != nil {
+			response.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(response).Encode(invalidParameter("cursor", value, err.Error()))
+			return
+		}
+	}
+
+	hits := QAEngineStore.Search.Search(query)
+	result := ResultSearch{Err: false, Message: "Successfully searched the questions", Data: []SearchResult{}, Total: len(hits)}
+	start := 0
+	if after != nil {
+		start = searchStart(hits, *after)
+	}
+	if start < len(hits) {
+		end := start + limit
+		if end < len(hits) {
+			result.HasMore = true
+			result.NextCursor = encodeSearchCursor(hits[end-1])
+		} else {
+			end = len(hits)
+		}
+
+		for _, hit := range hits[start:end] {
+			question, err := QAEngineStore.Questions.FindByID(context.TODO(), hit.ID)
+			if err == store.ErrNotFound {
+				continue
+			} else if err != nil {
+				response.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+				return
+			}
+			presentQuestion(&question, false)
+			result.Data = append(result.Data, SearchResult{Question: question, Score: hit.Score})
+		}
+	}
+
+	pointers := make([]*model.Question, len(result.Data))
+	for i := range result.Data {
+		pointers[i] = &result.Data[i].Question
+	}
+	if !attachReputation(response, QAEngineStore, pointers...) {
+		return
+	}
+
+	json.NewEncoder(response).Encode(result)
+}
@@ -0,0 +1,105 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"example.org/model"
+	"example.org/search"
+	"example.org/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func searchPage(t *testing.T, QAEngineStore *store.Store, q string, cursor string) ResultSearch {
+	t.Helper()
+
+	target := "/search?limit=2&q=" + url.QueryEscape(q)
+	if cursor != "" {
+		target += "&cursor=" + cursor
+	}
+	recorder := httptest.NewRecorder()
+	SearchQuestions(recorder, httptest.NewRequest("GET", target, nil), QAEngineStore)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("search %s: %d %s", target, recorder.Code, recorder.Body)
+	}
+	var result ResultSearch
+	if err := json.NewDecoder(recorder.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// Searches the rest of the pages from the cursor, returning the titles found
+func searchRest(t *testing.T, QAEngineStore *store.Store, q string, cursor string) []string {
+	t.Helper()
+
+	titles := []string{}
+	for pages := 0; cursor != "" && pages < 100; pages++ {
+		result := searchPage(t, QAEngineStore, q, cursor)
+		for _, found := range result.Data {
+			titles = append(titles, found.Title)
+		}
+		cursor = result.NextCursor
+	}
+	return titles
+}
+
+func askQuestion(t *testing.T, QAEngineStore *store.Store, title string, votes int) primitive.ObjectID {
+	t.Helper()
+
+	question := model.Question{ID: primitive.NewObjectID(), Title: title, Content: "goroutine", Tags: []string{"go"}}
+	if err := QAEngineStore.Questions.Insert(context.Background(), &question); err != nil {
+		t.Fatal(err)
+	}
+	if err := QAEngineStore.Questions.IncrementVotes(context.Background(), question.ID, votes, 0); err != nil {
+		t.Fatal(err)
+	}
+	return question.ID
+}
+
+// Questions asked or deleted between two pages of a search do not repeat or skip the ones still to come
+func TestSearchCursorStability(t *testing.T) {
+	QAEngineStore := store.NewMemoryStore()
+	ids := []primitive.ObjectID{}
+	for i := 0; i < 6; i++ {
+		ids = append(ids, askQuestion(t, QAEngineStore, fmt.Sprintf("q%d", i), 10-i))
+	}
+
+	first := searchPage(t, QAEngineStore, "goroutine", "")
+	if first.Total != 6 || len(first.Data) != 2 || first.Data[0].Title != "q0" || first.Data[1].Title != "q1" || !first.HasMore {
+		t.Fatalf("first page: %+v", first)
+	}
+
+	// A question ranking first changes every score, as the word is now in one more question
+	askQuestion(t, QAEngineStore, "new", 20)
+	if rest := searchRest(t, QAEngineStore, "goroutine", first.NextCursor); fmt.Sprint(rest) != "[q2 q3 q4 q5]" {
+		t.Errorf("pages after a question was asked: %v, want [q2 q3 q4 q5]", rest)
+	}
+
+	// The last question of a page can be gone by the time the next page is asked for. The next page
+	// then starts after its score, which only stays put when the search has no words to score
+	first = searchPage(t, QAEngineStore, "tag:go", "")
+	second := searchPage(t, QAEngineStore, "tag:go", first.NextCursor)
+	if len(second.Data) != 2 || second.Data[1].Title != "q2" {
+		t.Fatalf("second page: %+v", second)
+	}
+	if _, err := QAEngineStore.Questions.SetStatus(context.Background(), ids[2], model.QuestionStatus{State: model.QuestionDeleted}); err != nil {
+		t.Fatal(err)
+	}
+	if rest := searchRest(t, QAEngineStore, "tag:go", second.NextCursor); fmt.Sprint(rest) != "[q3 q4 q5]" {
+		t.Errorf("pages after the cursor question was deleted: %v, want [q3 q4 q5]", rest)
+	}
+}
+
+func TestInvalidSearchCursor(t *testing.T) {
+	for _, value := range []string{"not base64!", "bm90IGpzb24", encodeSearchCursor(search.Hit{Score: 1})} {
+		if _, err := decodeSearchCursor(value); err == nil {
+			t.Errorf("cursor %q accepted", value)
+		}
+	}
+}
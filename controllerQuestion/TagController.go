@@ -0,0 +1,236 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxTagsPerQuestion = 5
+	maxTagLength       = 25
+	maxExcerptLength   = 500
+	maxWikiLength      = 30000
+	defaultTagLimit    = 36
+	maxTagLimit        = 100
+)
+
+// Tags are lower case words joined by dashes, + # and . are allowed for names like c++, c# or asp.net
+var tagPattern = regexp.MustCompile(`^[a-z0-9+#][a-z0-9+#.-]*$`)
+
+type ResultTags struct {
+	Err     bool             `json:"error"`
+	Message string           `json:"message"`
+	Data    []model.TagCount `json:"data"`
+}
+
+// TagInfo is the tag page, the description of the tag along with how it is used
+type TagInfo struct {
+	model.Tag
+	Stats model.TagStats `json:"stats"`
+	// The approved synonyms filed under the tag
+	Synonyms []string `json:"synonyms"`
+}
+
+type TagWikiRequest struct {
+	Excerpt string `json:"excerpt"`
+	Wiki    string `json:"wiki"`
+}
+
+type ResultTag struct {
+	Err     bool    `json:"error"`
+	Message string  `json:"message"`
+	Data    TagInfo `json:"data"`
+}
+
+type ResultTagWiki struct {
+	Err     bool      `json:"error"`
+	Message string    `json:"message"`
+	Data    model.Tag `json:"data"`
+}
+
+// Lower cases the tag and joins its words with dashes, "Google App Engine" becomes google-app-engine
+func normalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+// Normalizes and validates the tags of a question. Tags that end up the same are only kept once
+func normalizeTags(tags []string) ([]string, *ResultInvalidParameter) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		name := normalizeTag(tag)
+		if len(name) > maxTagLength || !tagPattern.MatchString(name) {
+			return nil, invalidParameter("tags", tag, "Tags are up to "+strconv.Itoa(maxTagLength)+" letters, digits and the characters + # . -")
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	if len(normalized) > maxTagsPerQuestion {
+		return nil, invalidParameter("tags", strings.Join(normalized, ","), "A question can have at most "+strconv.Itoa(maxTagsPerQuestion)+" tags")
+	}
+	return normalized, nil
+}
+
+// Reads the comma separated tags parameter of a listing and whether any or all of them have to match
+func parseTagFilter(request *http.Request, query *store.QuestionQuery) *ResultInvalidParameter {
+	parameters := request.URL.Query()
+
+	if tags := parameters.Get("tags"); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			name := normalizeTag(tag)
+			if name == "" || !tagPattern.MatchString(name) {
+				return invalidParameter("tags", tag, "Invalid tag "+strconv.Quote(tag))
+			}
+			query.Tags = append(query.Tags, name)
+		}
+	}
+
+	switch match := parameters.Get("match"); match {
+	case "", "all":
+		query.AnyTag = false
+	case "any":
+		query.AnyTag = true
+	default:
+		return invalidParameter("match", match, "Unknown match "+strconv.Quote(match), "all", "any")
+	}
+	return nil
+}
+
+// Lists the questions filed under the tags parameter, all of them by default or any of them with
+// match=any. The questions are sorted like GetAllQuestionsByOrder, newest first by default
+func GetTaggedQuestions(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	sort, found := sortFromRequest(response, request, store.SortNewest)
+	if !found {
+		return
+	}
+	if request.URL.Query().Get("tags") == "" {
+		response.Header().Add("Content-Type", "application/json")
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("tags", "", "At least one tag is needed"))
+		return
+	}
+	listQuestions(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig, sort, true)
+}
+
+// Lists the most used tags with the number of questions filed under each
+func GetPopularTags(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+
+	limit := defaultTagLimit
+	if value := request.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxTagLimit {
+			response.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(response).Encode(invalidParameter("limit", value, "limit must be a number between 1 and "+strconv.Itoa(maxTagLimit)))
+			return
+		}
+		limit = parsed
+	}
+
+	tags, err := QAEngineStore.Questions.PopularTags(context.TODO(), limit)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultTags{Err: false, Message: "Successfully fetched the tags", Data: tags})
+}
+
+// Returns the tag page, its description, usage and synonyms. A synonym shows the page of its target
+func GetTag(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+
+	name := normalizeTag(mux.Vars(request)["tag"])
+	synonym, err := QAEngineStore.Tags.FindSynonym(context.TODO(), name)
+	if err == nil && synonym.Status == model.SynonymApproved {
+		name = synonym.Target
+	}
+
+	tag, err := QAEngineStore.Tags.FindByName(context.TODO(), name)
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Tag not found"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	stats, err := QAEngineStore.Questions.TagStats(context.TODO(), tag.Name)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	synonyms, err := QAEngineStore.Tags.FindSynonymsByTarget(context.TODO(), tag.Name)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	info := TagInfo{Tag: tag, Stats: stats, Synonyms: []string{}}
+	for _, synonym := range synonyms {
+		if synonym.Status == model.SynonymApproved {
+			info.Synonyms = append(info.Synonyms, synonym.Source)
+		}
+	}
+
+	json.NewEncoder(response).Encode(ResultTag{Err: false, Message: "Successfully fetched the tag", Data: info})
+}
+
+// Replaces the excerpt and wiki of the tag in the path, any logged in user can edit them
+func UpdateTagWiki(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	var wikiRequest TagWikiRequest
+	json.NewDecoder(request.Body).Decode(&wikiRequest)
+	defer request.Body.Close()
+
+	excerpt := strings.TrimSpace(wikiRequest.Excerpt)
+	if len(excerpt) > maxExcerptLength {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The excerpt is limited to " + strconv.Itoa(maxExcerptLength) + " characters"})
+		return
+	}
+	if len(wikiRequest.Wiki) > maxWikiLength {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "The wiki is limited to " + strconv.Itoa(maxWikiLength) + " characters"})
+		return
+	}
+
+	tag, err := QAEngineStore.Tags.UpdateWiki(context.TODO(), normalizeTag(mux.Vars(request)["tag"]), excerpt, wikiRequest.Wiki, claims.Username)
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Tag not found"})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	json.NewEncoder(response).Encode(ResultTagWiki{Err: false, Message: "Tag wiki updated", Data: tag})
+}
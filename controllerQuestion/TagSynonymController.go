@@ -0,0 +1,378 @@
+package controllerQuestion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+	"github.com/gorilla/mux"
+)
+
+type TagSynonymRequest struct {
+	Synonym string `json:"synonym"`
+}
+
+type TagMergeRequest struct {
+	Into string `json:"into"`
+}
+
+type ResultTagSynonym struct {
+	Err     bool             `json:"error"`
+	Message string           `json:"message"`
+	Data    model.TagSynonym `json:"data"`
+}
+
+type ResultTagSynonyms struct {
+	Err     bool               `json:"error"`
+	Message string             `json:"message"`
+	Data    []model.TagSynonym `json:"data"`
+}
+
+// TagMerge is the outcome of merging a tag into another
+type TagMerge struct {
+	Synonym model.TagSynonym `json:"synonym"`
+	// Number of questions that were filed under the merged tag
+	Retagged int `json:"retagged"`
+}
+
+type ResultTagMerge struct {
+	Err     bool     `json:"error"`
+	Message string   `json:"message"`
+	Data    TagMerge `json:"data"`
+}
+
+// Files the tags under their approved synonyms, dropping the tags that end up twice
+func applyTagSynonyms(QAEngineStore *store.Store, tags []string) ([]string, error) {
+	applied := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		synonym, err := QAEngineStore.Tags.FindSynonym(context.TODO(), tag)
+		if err != nil && err != store.ErrNotFound {
+			return nil, err
+		}
+		if err == nil && synonym.Status == model.SynonymApproved {
+			tag = synonym.Target
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			applied = append(applied, tag)
+		}
+	}
+	return applied, nil
+}
+
+// Checks that mapping source onto target keeps every synonym a single step, so no tag is both the
+// source and the target of approved synonyms. Writes the conflict response when it is not
+func checkSynonymChain(response http.ResponseWriter, QAEngineStore *store.Store, source string, target string) bool {
+	existing, err := QAEngineStore.Tags.FindSynonym(context.TODO(), target)
+	if err == nil && existing.Status == model.SynonymApproved {
+		response.WriteHeader(http.StatusConflict)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: target + " is itself a synonym of " + existing.Target})
+		return false
+	} else if err != nil && err != store.ErrNotFound {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return false
+	}
+
+	synonyms, err := QAEngineStore.Tags.FindSynonymsByTarget(context.TODO(), source)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return false
+	}
+	for _, synonym := range synonyms {
+		if synonym.Status == model.SynonymApproved {
+			response.WriteHeader(http.StatusConflict)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: source + " has synonyms of its own, merge them first"})
+			return false
+		}
+	}
+	return true
+}
+
+// Verifies the request and checks the user is a moderator, writing the error response when not
+func verifyModerator(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) (*model.Claims, bool) {
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return nil, false
+	}
+
+	if !QAEngineConfig.IsModerator(claims.Username) {
+		response.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Only moderators can do this"})
+		return nil, false
+	}
+	return claims, true
+}
+
+// Reports whether the request comes from a logged in moderator, for views that show them more
+func isModerator(request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) bool {
+	claims := middlewares.OptionalClaims(request, QAEngineStore, QAEngineKeys)
+	return claims != nil && QAEngineConfig.IsModerator(claims.Username)
+}
+
+// Reads a tag name from the request, writing the error response when it is not a valid tag
+func tagFromRequest(response http.ResponseWriter, parameter string, value string) (string, bool) {
+	name := normalizeTag(value)
+	if name == "" || len(name) > maxTagLength || !tagPattern.MatchString(name) {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter(parameter, value, "Invalid tag "+strconv.Quote(value)))
+		return "", false
+	}
+	return name, true
+}
+
+// Proposes the synonym in the body for the tag in the path. Moderators approve or reject the proposal
+func ProposeTagSynonym(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	var synonymRequest TagSynonymRequest
+	json.NewDecoder(request.Body).Decode(&synonymRequest)
+	defer request.Body.Close()
+
+	target, valid := tagFromRequest(response, "tag", mux.Vars(request)["tag"])
+	if !valid {
+		return
+	}
+	source, valid := tagFromRequest(response, "synonym", synonymRequest.Synonym)
+	if !valid {
+		return
+	}
+	if source == target {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("synonym", synonymRequest.Synonym, "A tag cannot be a synonym of itself"))
+		return
+	}
+
+	if _, err = QAEngineStore.Tags.FindByName(context.TODO(), target); err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Tag not found"})
+		return
+	}
+	if !checkSynonymChain(response, QAEngineStore, source, target) {
+		return
+	}
+
+	synonym := model.TagSynonym{
+		Source:     source,
+		Target:     target,
+		Status:     model.SynonymPending,
+		ProposedBy: claims.Username,
+		ProposedAt: time.Now(),
+	}
+	err = QAEngineStore.Tags.InsertSynonym(context.TODO(), &synonym)
+	if err == store.ErrDuplicate {
+		// A rejected proposal can be made again, the others stand until they are reviewed
+		existing, findErr := QAEngineStore.Tags.FindSynonym(context.TODO(), source)
+		if findErr != nil || existing.Status != model.SynonymRejected {
+			response.WriteHeader(http.StatusConflict)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: source + " already has a synonym proposed"})
+			return
+		}
+		err = QAEngineStore.Tags.ReplaceSynonym(context.TODO(), &synonym)
+	}
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	json.NewEncoder(response).Encode(ResultTagSynonym{Err: false, Message: "Synonym proposed", Data: synonym})
+}
+
+// Lists every synonym of the tag in the path, whatever its status
+func GetTagSynonyms(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+
+	synonyms, err := QAEngineStore.Tags.FindSynonymsByTarget(context.TODO(), normalizeTag(mux.Vars(request)["tag"]))
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultTagSynonyms{Err: false, Message: "Successfully fetched the synonyms", Data: synonyms})
+}
+
+// Lists the synonyms with the status parameter, the pending ones waiting for review by default
+func GetTagSynonymsByStatus(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+
+	status := request.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = model.SynonymPending
+	case model.SynonymPending, model.SynonymApproved, model.SynonymRejected:
+	default:
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("status", status, "Unknown status "+strconv.Quote(status),
+			model.SynonymPending, model.SynonymApproved, model.SynonymRejected))
+		return
+	}
+
+	synonyms, err := QAEngineStore.Tags.FindSynonymsByStatus(context.TODO(), status)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultTagSynonyms{Err: false, Message: "Successfully fetched the synonyms", Data: synonyms})
+}
+
+// Approves the pending synonym in the path. New questions are filed under its target from then on
+func ApproveTagSynonym(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	reviewTagSynonym(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig, model.SynonymApproved)
+}
+
+// Rejects the pending synonym in the path
+func RejectTagSynonym(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	reviewTagSynonym(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig, model.SynonymRejected)
+}
+
+func reviewTagSynonym(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config, status string) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, allowed := verifyModerator(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	if !allowed {
+		return
+	}
+
+	source := normalizeTag(mux.Vars(request)["synonym"])
+	if status == model.SynonymApproved {
+		// Other synonyms may have been approved since this one was proposed
+		pending, err := QAEngineStore.Tags.FindSynonym(context.TODO(), source)
+		if err == nil && !checkSynonymChain(response, QAEngineStore, pending.Source, pending.Target) {
+			return
+		}
+	}
+
+	synonym, err := QAEngineStore.Tags.ReviewSynonym(context.TODO(), source, status, claims.Username)
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "No pending synonym " + source})
+		return
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	json.NewEncoder(response).Encode(ResultTagSynonym{Err: false, Message: "Synonym " + status, Data: synonym})
+}
+
+// Returns every question filed under the tag, whatever its state
+func taggedQuestions(QAEngineStore *store.Store, tag string) ([]model.Question, error) {
+	tagged := []model.Question{}
+	query := store.QuestionQuery{Sort: store.SortNewest, Limit: maxPageLimit, Tags: []string{tag}, States: model.QuestionStates}
+	for {
+		questions, hasMore, err := QAEngineStore.Questions.List(context.TODO(), query)
+		if err != nil {
+			return nil, err
+		}
+		tagged = append(tagged, questions...)
+		if !hasMore {
+			return tagged, nil
+		}
+		cursor := store.CursorOf(query, questions[len(questions)-1])
+		query.After = &cursor
+	}
+}
+
+// Merges the tag in the path into the one named by into. Every question filed under the tag is
+// retagged in a revision of its own, and the tag becomes an approved synonym so new questions are
+// filed under into as well
+func MergeTags(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, allowed := verifyModerator(response, request, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	if !allowed {
+		return
+	}
+
+	var mergeRequest TagMergeRequest
+	json.NewDecoder(request.Body).Decode(&mergeRequest)
+	defer request.Body.Close()
+
+	source, valid := tagFromRequest(response, "tag", mux.Vars(request)["tag"])
+	if !valid {
+		return
+	}
+	target, valid := tagFromRequest(response, "into", mergeRequest.Into)
+	if !valid {
+		return
+	}
+	if source == target {
+		response.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(response).Encode(invalidParameter("into", mergeRequest.Into, "A tag cannot be merged into itself"))
+		return
+	}
+	if !checkSynonymChain(response, QAEngineStore, source, target) {
+		return
+	}
+
+	now := time.Now()
+	synonym := model.TagSynonym{
+		Source:     source,
+		Target:     target,
+		Status:     model.SynonymApproved,
+		ProposedBy: claims.Username,
+		ProposedAt: now,
+		ReviewedBy: claims.Username,
+		ReviewedAt: now,
+	}
+	err := QAEngineStore.Tags.EnsureTags(context.TODO(), []string{target})
+	if err == nil {
+		err = QAEngineStore.Tags.ReplaceSynonym(context.TODO(), &synonym)
+	}
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	tagged, err := taggedQuestions(QAEngineStore, source)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	retagged, err := QAEngineStore.Questions.RenameTag(context.TODO(), source, target)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	for _, question := range tagged {
+		err = saveRetagRevision(QAEngineStore, question, claims.Username, "Merged "+source+" into "+target)
+		if err != nil {
+			response.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+			return
+		}
+	}
+
+	json.NewEncoder(response).Encode(ResultTagMerge{
+		Err:     false,
+		Message: "Merged " + source + " into " + target,
+		Data:    TagMerge{Synonym: synonym, Retagged: retagged},
+	})
+}
@@ -0,0 +1,180 @@
+package controllerQuestion
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"example.org/config"
+	"example.org/model"
+	"example.org/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VoteResult is the state of a vote after it was cast
+type VoteResult struct {
+	// The vote the user now holds on the target, up, down or none
+	Vote string `json:"vote"`
+	// The new vote count of the target
+	Votes int `json:"votes"`
+}
+
+type ResultVote struct {
+	Err     bool       `json:"error"`
+	Message string     `json:"message"`
+	Data    VoteResult `json:"data"`
+}
+
+var errInvalidVoteType = errors.New("votetype must be upvote, downvote or none")
+
+// How many times castVote casts a vote whose record another vote created in the meantime
+const maxVoteAttempts = 3
+
+// A step of a vote that failed. The message is what the client is told, the cause is kept so the
+// transaction can tell a write conflict, which it retries, from other errors
+type voteError struct {
+	message string
+	cause   error
+}
+
+func (e *voteError) Error() string {
+	return e.message
+}
+
+func (e *voteError) Unwrap() error {
+	return e.cause
+}
+
+// Reads the votetype of a request, upvote and downvote are the names older clients send
+func parseVoteType(voteType string) (string, error) {
+	switch voteType {
+	case "upvote", model.VoteUp:
+		return model.VoteUp, nil
+	case "downvote", model.VoteDown:
+		return model.VoteDown, nil
+	case model.VoteNone, "retract":
+		return model.VoteNone, nil
+	}
+	return "", errInvalidVoteType
+}
+
+func voteValue(vote string) int {
+	switch vote {
+	case model.VoteUp:
+		return 1
+	case model.VoteDown:
+		return -1
+	}
+	return 0
+}
+
+func countOf(kind string, vote string) int {
+	if vote == kind {
+		return 1
+	}
+	return 0
+}
+
+// A question, or one of its answers when answerID is set
+type voteTarget struct {
+	question *model.Question
+	answerID primitive.ObjectID
+}
+
+// The record the vote of the user on the target is stored under
+func (target voteTarget) key(voterUsername string) model.VoteKey {
+	return model.VoteKey{Username: voterUsername, QuestionID: target.question.ID, AnswerID: target.answerID}
+}
+
+// The user who posted the target
+func (target voteTarget) author() string {
+	if target.answerID.IsZero() {
+		return target.question.Username
+	}
+	for _, answer := range target.question.Answers {
+		if answer.ID == target.answerID {
+			return answer.Username
+		}
+	}
+	return ""
+}
+
+func (target voteTarget) votes() int {
+	if target.answerID.IsZero() {
+		return target.question.Votes
+	}
+	for _, answer := range target.question.Answers {
+		if answer.ID == target.answerID {
+			return answer.Votes
+		}
+	}
+	return 0
+}
+
+// Moves the vote of the user on the target to newVote and adjusts the vote count by the difference,
+// so switching from up to down counts twice and retracting undoes the earlier vote.
+// The vote record is swapped atomically and the count moves by the difference to the vote it replaced,
+// so concurrent votes of the same user each apply their own step. The reputation of the author moves
+// along with the vote. The swap, the count and the reputation are written in one transaction, so where
+// the store supports transactions a failed step leaves the record, the count and the reputation as
+// they were. Casting the vote the user already holds changes nothing
+func castVote(QAEngineStore *store.Store, QAEngineConfig *config.Config, voterUsername string, voterEmail string, target voteTarget, newVote string) (VoteResult, error) {
+	var err error
+	for attempt := 0; attempt < maxVoteAttempts; attempt++ {
+		err = QAEngineStore.Transactions.Run(context.TODO(), func(ctx context.Context) error {
+			return recordVote(ctx, QAEngineStore, QAEngineConfig, voterUsername, voterEmail, target, newVote)
+		})
+		// The first vote of the user on the target raced another one creating the record. The record
+		// exists now, so casting the vote again replaces it
+		if !errors.Is(err, store.ErrDuplicate) {
+			break
+		}
+	}
+	if _, failedStep := err.(*voteError); failedStep {
+		return VoteResult{}, err
+	} else if err != nil {
+		// The transaction itself could not be committed
+		return VoteResult{}, errors.New("Failed to record the vote")
+	}
+
+	// Read the count back, other votes may have landed in the meantime
+	question, err := QAEngineStore.Questions.FindByID(context.TODO(), target.question.ID)
+	if err != nil {
+		return VoteResult{}, errors.New("Failed to read the vote count")
+	}
+	target.question = &question
+	return VoteResult{Vote: newVote, Votes: target.votes()}, nil
+}
+
+// Writes the steps of castVote with the context of its transaction
+func recordVote(ctx context.Context, QAEngineStore *store.Store, QAEngineConfig *config.Config, voterUsername string, voterEmail string, target voteTarget, newVote string) error {
+	previousVote, err := QAEngineStore.Votes.Swap(ctx, model.Vote{
+		Key:   target.key(voterUsername),
+		Email: voterEmail,
+		Title: target.question.Title,
+		Vote:  newVote,
+		Date:  time.Now(),
+	})
+	if err != nil {
+		return &voteError{"Failed to record the vote", err}
+	}
+
+	delta := voteValue(newVote) - voteValue(previousVote)
+	if delta == 0 {
+		return nil
+	}
+	if target.answerID.IsZero() {
+		upvotes := countOf(model.VoteUp, newVote) - countOf(model.VoteUp, previousVote)
+		downvotes := countOf(model.VoteDown, newVote) - countOf(model.VoteDown, previousVote)
+		err = QAEngineStore.Questions.IncrementVotes(ctx, target.question.ID, upvotes, downvotes)
+	} else {
+		err = QAEngineStore.Questions.IncrementAnswerVotes(ctx, target.question.ID, target.answerID, delta)
+	}
+	if err != nil {
+		return &voteError{"Failed to update the vote count", err}
+	}
+	if err = moveVoteReputation(ctx, QAEngineStore, QAEngineConfig, voterUsername, target, previousVote, newVote); err != nil {
+		return &voteError{"Failed to update the reputation", err}
+	}
+	return nil
+}
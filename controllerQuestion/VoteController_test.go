@@ -0,0 +1,59 @@
+package controllerQuestion
+
+import (
+	"context"
+	"testing"
+
+	"example.org/config"
+	"example.org/model"
+	"example.org/store"
+)
+
+// A vote store whose swaps lose the race to create the vote record the given number of times
+type racingVotes struct {
+	store.VoteStore
+	races int
+	swaps int
+}
+
+func (votes *racingVotes) Swap(ctx context.Context, vote model.Vote) (string, error) {
+	votes.swaps++
+	if votes.swaps <= votes.races {
+		return "", store.ErrDuplicate
+	}
+	return votes.VoteStore.Swap(ctx, vote)
+}
+
+// A vote that loses the race to create its record is cast again, a bounded number of times
+func TestCastVoteRace(t *testing.T) {
+	for _, races := range []int{1, maxVoteAttempts} {
+		QAEngineStore := store.NewMemoryStore()
+		QAEngineConfig := config.Default()
+		for _, username := range []string{"asker", "voter"} {
+			user := model.UserModel{Username: username, Email: username + "@example.org"}
+			if err := QAEngineStore.Users.Insert(context.Background(), &user); err != nil {
+				t.Fatal(err)
+			}
+		}
+		question := model.Question{Username: "asker", Title: "Racing votes"}
+		if err := QAEngineStore.Questions.Insert(context.Background(), &question); err != nil {
+			t.Fatal(err)
+		}
+		votes := &racingVotes{VoteStore: QAEngineStore.Votes, races: races}
+		QAEngineStore.Votes = votes
+
+		result, err := castVote(QAEngineStore, &QAEngineConfig, "voter", "voter@example.org", voteTarget{question: &question}, model.VoteUp)
+		if races == maxVoteAttempts {
+			if err == nil || votes.swaps != maxVoteAttempts {
+				t.Errorf("%d lost races: err = %v after %d swaps, want an error after %d", races, err, votes.swaps, maxVoteAttempts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d lost races: %v", races, err)
+		}
+		if result.Votes != 1 || votes.swaps != races+1 {
+			t.Errorf("%d lost races: %+v after %d swaps, want 1 vote after %d", races, result, votes.swaps, races+1)
+		}
+	}
+}
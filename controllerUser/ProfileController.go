@@ -0,0 +1,153 @@
+package controllerUser
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/reputation"
+	"example.org/store"
+	"example.org/tokens"
+	"github.com/gorilla/mux"
+)
+
+// Profile is what everyone can see of a user, the email, phone and password stay private
+type Profile struct {
+	Username   string   `json:"username"`
+	Country    string   `json:"country"`
+	City       string   `json:"city"`
+	Reputation int      `json:"reputation"`
+	Privileges []string `json:"privileges"`
+}
+
+type ResultProfile struct {
+	Err     bool    `json:"error"`
+	Message string  `json:"message"`
+	Data    Profile `json:"data"`
+}
+
+type ResultReputationLedger struct {
+	Err     bool                    `json:"error"`
+	Message string                  `json:"message"`
+	Data    []model.ReputationEntry `json:"data"`
+}
+
+// ReputationRecount is the reputation of a user before and after adding up the ledger again
+type ReputationRecount struct {
+	Username   string `json:"username"`
+	Previous   int    `json:"previous"`
+	Reputation int    `json:"reputation"`
+	Entries    int    `json:"entries"`
+}
+
+type ResultReputationRecount struct {
+	Err     bool              `json:"error"`
+	Message string            `json:"message"`
+	Data    ReputationRecount `json:"data"`
+}
+
+// Finds the user named in the path, writing the error response when there is none
+func userFromPath(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) (model.UserReturnModel, bool) {
+	user, err := QAEngineStore.Users.FindByUsername(context.TODO(), mux.Vars(request)["username"])
+	if err == store.ErrNotFound {
+		response.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "User not found"})
+		return user, false
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return user, false
+	}
+	return user, true
+}
+
+// Gets the public profile of the user in the path along with their reputation and the privileges it earned them
+func GetUserProfile(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	user, found := userFromPath(response, request, QAEngineStore)
+	if !found {
+		return
+	}
+
+	privileges := []string{}
+	for _, privilege := range config.Privileges {
+		if QAEngineConfig.HasPrivilege(user.Username, user.Reputation, privilege) {
+			privileges = append(privileges, privilege)
+		}
+	}
+	json.NewEncoder(response).Encode(ResultProfile{
+		Err:     false,
+		Message: "Successfully fetched the user",
+		Data: Profile{
+			Username:   user.Username,
+			Country:    user.Country,
+			City:       user.City,
+			Reputation: user.Reputation,
+			Privileges: privileges,
+		},
+	})
+}
+
+// Lists the reputation ledger of the user in the path, oldest first, so every point can be traced to a vote or an accepted answer
+func GetReputationLedger(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store) {
+	response.Header().Add("Content-Type", "application/json")
+
+	user, found := userFromPath(response, request, QAEngineStore)
+	if !found {
+		return
+	}
+	entries, err := QAEngineStore.Reputation.List(context.TODO(), user.Username)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultReputationLedger{Err: false, Message: "Successfully fetched the reputation", Data: entries})
+}
+
+// Adds up the ledger of the user in the path again, with the current points and daily cap, and stores
+// the result as their reputation. Only moderators can recompute the reputation
+func RecomputeReputation(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) {
+	response.Header().Add("Content-Type", "application/json")
+
+	claims, err := middlewares.VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+
+	// Unauthorized access
+	if err != nil {
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	if !QAEngineConfig.IsModerator(claims.Username) {
+		response.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: "Only moderators can do this"})
+		return
+	}
+
+	user, found := userFromPath(response, request, QAEngineStore)
+	if !found {
+		return
+	}
+	entries, err := QAEngineStore.Reputation.List(context.TODO(), user.Username)
+	if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+
+	recount := ReputationRecount{
+		Username:   user.Username,
+		Previous:   user.Reputation,
+		Reputation: reputation.Replay(entries, QAEngineConfig.ReputationDailyCap),
+		Entries:    len(entries),
+	}
+	if err = QAEngineStore.Users.SetReputation(context.TODO(), user.Username, recount.Reputation); err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(response).Encode(Result{Err: true, Message: err.Error()})
+		return
+	}
+	json.NewEncoder(response).Encode(ResultReputationRecount{Err: false, Message: "Reputation recomputed", Data: recount})
+}
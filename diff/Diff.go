@@ -0,0 +1,172 @@
+package diff
+
+import "strings"
+
+// Operations of a diff line
+const (
+	Equal  = "="
+	Insert = "+"
+	Delete = "-"
+)
+
+// Above this much work, the lines left after the common head and tail times the edits between them,
+// the texts are shown as replaced wholesale rather than diffed
+const maxWork = 10000000
+
+// Line is one unit of a diff, a line or a word, with whether it was kept, inserted or deleted
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines diffs two texts line by line
+func Lines(a string, b string) []Line {
+	return Compare(splitLines(a), splitLines(b))
+}
+
+// Words diffs two short texts such as titles word by word
+func Words(a string, b string) []Line {
+	return Compare(strings.Fields(a), strings.Fields(b))
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+// Compare returns the shortest edit turning a into b, found with Myers' algorithm in linear space.
+// Texts too far apart to diff within maxWork are shown as deleted and inserted wholesale
+func Compare(a []string, b []string) []Line {
+	head, tail := commonEnds(a, b)
+
+	lines := []Line{}
+	for _, text := range a[:head] {
+		lines = append(lines, Line{Op: Equal, Text: text})
+	}
+	middleA, middleB := a[head:len(a)-tail], b[head:len(b)-tail]
+	middle, ok := compareMiddle(nil, middleA, middleB, maxWork/(len(middleA)+len(middleB)+1))
+	if !ok {
+		middle = replace(nil, middleA, middleB)
+	}
+	lines = append(lines, middle...)
+	for _, text := range a[len(a)-tail:] {
+		lines = append(lines, Line{Op: Equal, Text: text})
+	}
+	return lines
+}
+
+// The lengths of the common head and tail of a and b, which do not overlap
+func commonEnds(a []string, b []string) (int, int) {
+	head := 0
+	for head < len(a) && head < len(b) && a[head] == b[head] {
+		head++
+	}
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && a[len(a)-1-tail] == b[len(b)-1-tail] {
+		tail++
+	}
+	return head, tail
+}
+
+func replace(lines []Line, a []string, b []string) []Line {
+	for _, text := range a {
+		lines = append(lines, Line{Op: Delete, Text: text})
+	}
+	for _, text := range b {
+		lines = append(lines, Line{Op: Insert, Text: text})
+	}
+	return lines
+}
+
+// Appends the shortest edit turning a into b to lines. The edit is split at the middle snake and both
+// halves are diffed in turn, so only the furthest reaching paths of the current step are held. Returns
+// false when a and b are more than limit edits apart in either direction
+func compareMiddle(lines []Line, a []string, b []string, limit int) ([]Line, bool) {
+	head, tail := commonEnds(a, b)
+	for _, text := range a[:head] {
+		lines = append(lines, Line{Op: Equal, Text: text})
+	}
+	common := a[len(a)-tail:]
+	a, b = a[head:len(a)-tail], b[head:len(b)-tail]
+
+	if len(a) == 0 || len(b) == 0 {
+		lines = replace(lines, a, b)
+	} else {
+		x, y, u, v, ok := middleSnake(a, b, limit)
+		if !ok {
+			return lines, false
+		}
+		if lines, ok = compareMiddle(lines, a[:x], b[:y], limit); !ok {
+			return lines, false
+		}
+		for _, text := range a[x:u] {
+			lines = append(lines, Line{Op: Equal, Text: text})
+		}
+		if lines, ok = compareMiddle(lines, a[u:], b[v:], limit); !ok {
+			return lines, false
+		}
+	}
+
+	for _, text := range common {
+		lines = append(lines, Line{Op: Equal, Text: text})
+	}
+	return lines, true
+}
+
+// Finds the snake, a run of equal lines from (x, y) to (u, v), in the middle of a shortest edit of a
+// into b by searching from both ends at once until the paths overlap. Diagonal k holds the points
+// where x - y = k, forward[k] is the furthest x reached on it from the start and backward[k] the
+// furthest distance reached on it from the end, k counted from the end there
+func middleSnake(a []string, b []string, limit int) (int, int, int, int, bool) {
+	n, m := len(a), len(b)
+	steps := (n + m + 1) / 2
+	if steps > limit {
+		steps = limit
+	}
+	offset := steps + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	delta := n - m
+	odd := delta%2 != 0
+
+	for d := 0; d <= steps; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			forward[offset+k] = x
+			if reverse := delta - k; odd && reverse >= -(d-1) && reverse <= d-1 && x+backward[offset+reverse] >= n {
+				return startX, startY, x, y, true
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x, y = x+1, y+1
+			}
+			backward[offset+k] = x
+			if ahead := delta - k; !odd && ahead >= -d && ahead <= d && x+forward[offset+ahead] >= n {
+				return n - x, m - y, n - startX, m - startY, true
+			}
+		}
+	}
+	return 0, 0, 0, 0, false
+}
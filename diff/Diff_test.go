@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// The texts the diff turns from and into, read back from its lines
+func sides(lines []Line) ([]string, []string) {
+	a, b := []string{}, []string{}
+	for _, line := range lines {
+		if line.Op != Insert {
+			a = append(a, line.Text)
+		}
+		if line.Op != Delete {
+			b = append(b, line.Text)
+		}
+	}
+	return a, b
+}
+
+func edits(lines []Line) int {
+	count := 0
+	for _, line := range lines {
+		if line.Op != Equal {
+			count++
+		}
+	}
+	return count
+}
+
+// The length of the longest common subsequence, worked out the quadratic way
+func longestCommon(a []string, b []string) int {
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	return common[0][0]
+}
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want string
+	}{
+		{"", "", ""},
+		{"a b c", "a b c", "=a =b =c"},
+		{"", "a b", "+a +b"},
+		{"a b", "", "-a -b"},
+		{"a b c", "a x c", "=a -b +x =c"},
+		{"a b c d", "a c d e", "=a -b =c =d +e"},
+		{"x a b", "a b y", "-x =a =b +y"},
+	}
+	for _, c := range cases {
+		parts := []string{}
+		for _, line := range Compare(strings.Fields(c.a), strings.Fields(c.b)) {
+			parts = append(parts, line.Op+line.Text)
+		}
+		if got := strings.Join(parts, " "); got != c.want {
+			t.Errorf("Compare(%q, %q) = %q, want %q", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+// On random texts the diff turns a into b with as few edits as the longest common subsequence allows
+func TestCompareShortest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	text := func() []string {
+		words := make([]string, random.Intn(40))
+		for i := range words {
+			words[i] = string(rune('a' + random.Intn(4)))
+		}
+		return words
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := text(), text()
+		lines := Compare(a, b)
+		gotA, gotB := sides(lines)
+		if fmt.Sprint(gotA) != fmt.Sprint(a) || fmt.Sprint(gotB) != fmt.Sprint(b) {
+			t.Fatalf("Compare(%v, %v) = %v does not turn one into the other", a, b, lines)
+		}
+		if got, want := edits(lines), len(a)+len(b)-2*longestCommon(a, b); got != want {
+			t.Fatalf("Compare(%v, %v) takes %d edits, want %d", a, b, got, want)
+		}
+	}
+}
+
+// Texts too far apart are replaced wholesale, after their common head and tail, without the quadratic
+// table an exact diff of them would need
+func TestCompareTooFarApart(t *testing.T) {
+	a, b := []string{"head"}, []string{"head"}
+	for i := 0; i < 100000; i++ {
+		a = append(a, fmt.Sprint("a", i))
+		b = append(b, fmt.Sprint("b", i))
+	}
+	a, b = append(a, "tail"), append(b, "tail")
+
+	var lines []Line
+	allocated := testing.AllocsPerRun(1, func() { lines = Compare(a, b) })
+	if len(lines) != 200002 || lines[0].Op != Equal || lines[1].Op != Delete || lines[100001].Op != Insert || lines[200001].Op != Equal {
+		t.Fatalf("Compare of disjoint texts: %d lines", len(lines))
+	}
+	if allocated > 100 {
+		t.Errorf("Compare of disjoint texts made %v allocations", allocated)
+	}
+
+	// A few edits in a long text are still diffed
+	b = append([]string{}, a...)
+	b[500], b[50000] = "changed", "changed"
+	if got := edits(Compare(a, b)); got != 4 {
+		t.Errorf("two lines changed in a long text: %d edits, want 4", got)
+	}
+}
+
+func TestLines(t *testing.T) {
+	lines := Lines("one\r\ntwo\nthree", "one\ntwo\nfour")
+	if got := fmt.Sprint(lines); got != "[{= one} {= two} {- three} {+ four}]" {
+		t.Errorf("Lines = %s", got)
+	}
+	if got := Words("reverse  a list", "reverse a linked list"); edits(got) != 1 {
+		t.Errorf("Words = %v, want one insert", got)
+	}
+}
@@ -7,14 +7,20 @@ import (
 	"net/http"
 	"os"
 
+	"example.org/config"
 	"example.org/controllerAuth"
 	"example.org/controllerQuestion"
+	"example.org/controllerUser"
+	"example.org/store"
+	"example.org/tokens"
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var QAEngineDatabase *mongo.Database
+var QAEngineConfig *config.Config
+var QAEngineStore *store.Store
+var QAEngineKeys *tokens.KeyManager
 
 func HomeHandlerEndpoint(response http.ResponseWriter, request *http.Request)  {
 	response.Header().Set("Access-Control-Allow-Origin", "*")
@@ -22,62 +28,341 @@ func HomeHandlerEndpoint(response http.ResponseWriter, request *http.Request)  {
 }
 
 
-func main() {
-	os.Setenv("TOKEN_SECRET", "[031-024H0ODFHSKDOFASDF]SDFASDFASD")
-	clientOptions := options.Client().ApplyURI("mongodb://localhost:27017")
-
-	client, e := mongo.Connect(context.TODO(), clientOptions)
-	
-	if e != nil {
-		log.Fatal(e)
-	}
-	e = client.Ping(context.TODO(), nil)
-
-	if e != nil {
-		log.Fatal(e)
-	}
-
-	QAEngineDatabase = client.Database("QAEngine")
-	
+// Builds the routes of the API on top of the given store, keys and configuration
+func newRouter(QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config) *mux.Router {
 	router := mux.NewRouter()
 	router.HandleFunc("/", HomeHandlerEndpoint)
 
 	// Register a user to the database
 	router.HandleFunc("/user/register", func(response http.ResponseWriter, request *http.Request) {
-		controllerAuth.UserRegisterController(response, request, QAEngineDatabase)
+		controllerAuth.UserRegisterController(response, request, QAEngineStore, QAEngineConfig)
 	}).Methods("POST")
 
 	// Login a user to the application
 	router.HandleFunc("/user/login", func(rw http.ResponseWriter, r *http.Request) {
-		controllerAuth.UserLoginController(rw, r, QAEngineDatabase)
+		controllerAuth.UserLoginController(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// Exchange the refresh token for a new pair of tokens
+	router.HandleFunc("/user/token/refresh", func(rw http.ResponseWriter, r *http.Request) {
+		controllerAuth.RefreshTokenController(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// Logout the user and revoke the tokens of the session
+	router.HandleFunc("/user/logout", func(rw http.ResponseWriter, r *http.Request) {
+		controllerAuth.LogoutController(rw, r, QAEngineStore, QAEngineKeys)
 	}).Methods("POST")
 
 	// Add a new question to the database
 	router.HandleFunc("/user/question", func(rw http.ResponseWriter, r *http.Request) {
-		controllerQuestion.AddQuestion(rw, r, QAEngineDatabase)
+		controllerQuestion.AddQuestion(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
 	})
 
 	// Upvote route to add an upvote to the question
 	router.HandleFunc("/user/question/vote", func(rw http.ResponseWriter, r *http.Request) {
-		controllerQuestion.AddUpVoteToQuestion(rw, r, QAEngineDatabase)
+		controllerQuestion.AddUpVoteToQuestion(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
 	}).Methods("POST")
 
 	// Add a new answer to an existing question
 	router.HandleFunc("/user/question/answer", func(rw http.ResponseWriter, r *http.Request) {
-		controllerQuestion.AddAnswer(rw, r, QAEngineDatabase)
+		controllerQuestion.AddAnswer(rw, r, QAEngineStore, QAEngineKeys)
+	}).Methods("POST")
+
+	// Search the questions, their answers included
+	router.HandleFunc("/search", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.SearchQuestions(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// List the existing questions worded like the one being typed
+	router.HandleFunc("/questions/duplicates", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.CheckDuplicates(rw, r, QAEngineStore, QAEngineConfig)
+	}).Methods("GET")
+
+	// List the questions filed under the given tags, registered before /questions/{id} would match it
+	router.HandleFunc("/questions/tagged", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetTaggedQuestions(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("GET")
+
+	// Get a single question by its id
+	router.HandleFunc("/questions/{id}", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetQuestion(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("GET")
+
+	// Edit the title, content or tags of the question with the given id, its author or users with the edit privilege
+	router.HandleFunc("/questions/{id}", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.EditQuestion(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("PUT")
+
+	// Open, close, lock or delete the question with the given id
+	router.HandleFunc("/questions/{id}/state", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.SetQuestionState(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// Vote to close the question with the given id, needs the close-vote privilege
+	router.HandleFunc("/questions/{id}/close-votes", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.VoteToClose(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// List the questions waiting in a review queue, needs the review privilege
+	router.HandleFunc("/review/{queue}", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetReviewQueue(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("GET")
+
+	// List the revisions of the question with the given id
+	router.HandleFunc("/questions/{id}/revisions", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetQuestionRevisions(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// Get a revision of the question with what it changed
+	router.HandleFunc("/questions/{id}/revisions/{number}", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetQuestionRevision(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// Roll the question back to one of its revisions
+	router.HandleFunc("/questions/{id}/revisions/{number}/rollback", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.RollbackQuestion(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// Compare two revisions of the question, ?from=1&to=3
+	router.HandleFunc("/questions/{id}/diff", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.DiffQuestionRevisions(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// List the questions related to the question with the given id and the ones linked to or from it
+	router.HandleFunc("/questions/{id}/related", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetRelatedQuestions(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// Add a new answer to the question with the given id
+	router.HandleFunc("/questions/{id}/answers", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.AddAnswerToQuestion(rw, r, QAEngineStore, QAEngineKeys)
+	}).Methods("POST")
+
+	// List the comments on the question with the given id
+	router.HandleFunc("/questions/{id}/comments", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetComments(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// Comment on the question with the given id
+	router.HandleFunc("/questions/{id}/comments", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.AddComment(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// List the comments on an answer of the question
+	router.HandleFunc("/questions/{id}/answers/{answerid}/comments", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetComments(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// Comment on an answer of the question
+	router.HandleFunc("/questions/{id}/answers/{answerid}/comments", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.AddComment(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// Edit a comment, only allowed for its author shortly after posting it
+	router.HandleFunc("/comments/{commentid}", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.EditComment(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("PUT")
+
+	// Upvote a comment
+	router.HandleFunc("/comments/{commentid}/upvote", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.UpvoteComment(rw, r, QAEngineStore, QAEngineKeys)
+	}).Methods("POST")
+
+	// Withdraw the upvote on a comment
+	router.HandleFunc("/comments/{commentid}/upvote", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.RemoveCommentUpvote(rw, r, QAEngineStore, QAEngineKeys)
+	}).Methods("DELETE")
+
+	// Accept an answer of the question, only allowed for the author of the question
+	router.HandleFunc("/questions/{id}/answers/{answerid}/accept", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.AcceptAnswer(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// Remove the accepted mark from an answer
+	router.HandleFunc("/questions/{id}/answers/{answerid}/accept", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.UnacceptAnswer(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("DELETE")
+
+	// Upvote or downvote an answer
+	router.HandleFunc("/questions/{id}/answers/{answerid}/vote", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.AddVoteToAnswer(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// Edit an answer, only its author can
+	router.HandleFunc("/questions/{id}/answers/{answerid}", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.EditAnswer(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("PUT")
+
+	// Delete an answer, leaving a tombstone for the moderators
+	router.HandleFunc("/questions/{id}/answers/{answerid}", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.DeleteAnswer(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("DELETE")
+
+	// Restore a deleted answer, moderators only
+	router.HandleFunc("/questions/{id}/answers/{answerid}/undelete", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.UndeleteAnswer(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// List the revisions of an answer
+	router.HandleFunc("/questions/{id}/answers/{answerid}/revisions", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetAnswerRevisions(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("GET")
+
+	// Get a revision of an answer with what it changed
+	router.HandleFunc("/questions/{id}/answers/{answerid}/revisions/{number}", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetAnswerRevision(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("GET")
+
+	// List the most used tags
+	router.HandleFunc("/tags", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetPopularTags(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// Get the description and usage of a tag
+	router.HandleFunc("/tags/{tag}", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetTag(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// Edit the excerpt and wiki of a tag
+	router.HandleFunc("/tags/{tag}/wiki", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.UpdateTagWiki(rw, r, QAEngineStore, QAEngineKeys)
+	}).Methods("PUT")
+
+	// List the synonyms of a tag
+	router.HandleFunc("/tags/{tag}/synonyms", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetTagSynonyms(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// Propose a synonym for a tag
+	router.HandleFunc("/tags/{tag}/synonyms", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.ProposeTagSynonym(rw, r, QAEngineStore, QAEngineKeys)
+	}).Methods("POST")
+
+	// Merge a tag into another one and retag its questions, moderators only
+	router.HandleFunc("/tags/{tag}/merge", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.MergeTags(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// List the tag synonyms by status, the ones waiting for review by default
+	router.HandleFunc("/tag-synonyms", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.GetTagSynonymsByStatus(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// Approve a proposed tag synonym, moderators only
+	router.HandleFunc("/tag-synonyms/{synonym}/approve", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.ApproveTagSynonym(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// Reject a proposed tag synonym, moderators only
+	router.HandleFunc("/tag-synonyms/{synonym}/reject", func(rw http.ResponseWriter, r *http.Request) {
+		controllerQuestion.RejectTagSynonym(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
+	}).Methods("POST")
+
+	// Get the public profile of a user with their reputation
+	router.HandleFunc("/users/{username}", func(rw http.ResponseWriter, r *http.Request) {
+		controllerUser.GetUserProfile(rw, r, QAEngineStore, QAEngineConfig)
+	}).Methods("GET")
+
+	// List the reputation ledger of a user
+	router.HandleFunc("/users/{username}/reputation", func(rw http.ResponseWriter, r *http.Request) {
+		controllerUser.GetReputationLedger(rw, r, QAEngineStore)
+	}).Methods("GET")
+
+	// Add up the reputation ledger of a user again, moderators only
+	router.HandleFunc("/users/{username}/reputation/recompute", func(rw http.ResponseWriter, r *http.Request) {
+		controllerUser.RecomputeReputation(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
 	}).Methods("POST")
 
 	// Get All Questions
 	router.HandleFunc("/user/questions/all", func(rw http.ResponseWriter, r *http.Request) {
-		controllerQuestion.GetAllQuestions(rw, r, QAEngineDatabase)
+		controllerQuestion.GetAllQuestions(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
 	}).Methods("GET")
 
 	// Order the questions in the database and return it
 	router.HandleFunc("/user/questions/order", func(rw http.ResponseWriter, r *http.Request) {
-		controllerQuestion.GetAllQuestionsByOrder(rw, r, QAEngineDatabase)
+		controllerQuestion.GetAllQuestionsByOrder(rw, r, QAEngineStore, QAEngineKeys, QAEngineConfig)
 	})
 
-	http.ListenAndServe(":5000", router)
+	return router
+}
+
+
+func main() {
+	var e error
+	QAEngineConfig, e = config.Load(os.Args[1:])
+
+	if e != nil {
+		log.Fatal(e)
+	}
+
+	// Signing keys come from the key file, or a single HS256 token secret
+	if QAEngineConfig.KeysFile != "" {
+		QAEngineKeys, e = tokens.LoadKeyManager(QAEngineConfig.KeysFile)
+	} else if QAEngineConfig.TokenSecret != "" {
+		QAEngineKeys, e = tokens.NewHMACKeyManager("default", []byte(QAEngineConfig.TokenSecret))
+	} else {
+		log.Println("No JWT keys configured, using a random key. Sessions will not survive a restart")
+		QAEngineKeys, e = tokens.NewRandomKeyManager()
+	}
+	if e != nil {
+		log.Fatal(e)
+	}
+
+	switch QAEngineConfig.Store {
+	case "memory":
+		QAEngineStore = store.NewMemoryStore()
+	case "mongo":
+		clientOptions := options.Client().ApplyURI(QAEngineConfig.MongoURI)
+
+		client, e := mongo.Connect(context.TODO(), clientOptions)
+
+		if e != nil {
+			log.Fatal(e)
+		}
+		e = client.Ping(context.TODO(), nil)
+
+		if e != nil {
+			log.Fatal(e)
+		}
+
+		QAEngineDatabase := client.Database(QAEngineConfig.Database)
+		e = store.EnsureMongoIndexes(context.TODO(), QAEngineDatabase)
+
+		if e != nil {
+			log.Fatal(e)
+		}
+
+		e = store.MigrateLegacyVotes(context.TODO(), QAEngineDatabase)
+
+		if e != nil {
+			log.Fatal(e)
+		}
+
+		e = store.MigrateQuestions(context.TODO(), QAEngineDatabase)
+
+		if e != nil {
+			log.Fatal(e)
+		}
+
+		transactions, e := store.MongoSupportsTransactions(context.TODO(), client)
+
+		if e != nil {
+			log.Fatal(e)
+		}
+		if !transactions {
+			log.Println("MongoDB is not a replica set, the writes of a vote are not grouped in a transaction")
+		}
+
+		QAEngineStore = store.NewMongoStore(QAEngineDatabase, transactions)
+		e = store.BuildSearchIndex(context.TODO(), QAEngineStore)
+
+		if e != nil {
+			log.Fatal(e)
+		}
+	}
+
+	router := newRouter(QAEngineStore, QAEngineKeys, QAEngineConfig)
+
+	log.Fatal(http.ListenAndServe(QAEngineConfig.Address, router))
 
-	defer os.Unsetenv("TOKEN_SECRET")
 }
\ No newline at end of file
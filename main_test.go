@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"example.org/config"
+	"example.org/middlewares"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Serves the API in process on a memory store. The user named moderator is a moderator
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	QAEngineConfig := config.Default()
+	// New users hold every privilege, the tests register their users as they go
+	for _, privilege := range config.Privileges {
+		QAEngineConfig.Privileges[privilege] = 0
+	}
+	return newTestServerWithConfig(t, QAEngineConfig)
+}
+
+func newTestServerWithConfig(t *testing.T, QAEngineConfig config.Config) *httptest.Server {
+	t.Helper()
+
+	QAEngineConfig.Store = "memory"
+	QAEngineConfig.BcryptCost = bcrypt.MinCost
+	QAEngineConfig.Moderators = []string{"moderator"}
+
+	QAEngineKeys, err := tokens.NewRandomKeyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(newRouter(store.NewMemoryStore(), QAEngineKeys, &QAEngineConfig))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Sends body to the path and decodes the JSON response into result
+func post(t *testing.T, server *httptest.Server, token *http.Cookie, path string, body string, result interface{}) *http.Response {
+	t.Helper()
+	return send(t, server, token, "POST", path, body, result)
+}
+
+func send(t *testing.T, server *httptest.Server, token *http.Cookie, method string, path string, body string, result interface{}) *http.Response {
+	t.Helper()
+
+	request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != nil {
+		request.AddCookie(token)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if result != nil {
+		if err = json.NewDecoder(response.Body).Decode(result); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return response
+}
+
+// Registers and logs in a user, returning the access token cookie
+func login(t *testing.T, server *httptest.Server, username string) *http.Cookie {
+	t.Helper()
+
+	credentials := fmt.Sprintf(`{"username":%q,"password":"password","email":"%s@example.org"}`, username, username)
+	post(t, server, nil, "/user/register", credentials, nil)
+
+	response := post(t, server, nil, "/user/login", credentials, nil)
+	for _, cookie := range response.Cookies() {
+		if cookie.Name == "token" {
+			return cookie
+		}
+	}
+	t.Fatalf("login of %s returned no token", username)
+	return nil
+}
+
+// Casts a vote from a goroutine other than the test one, so failures are returned rather than fatal
+func vote(server *httptest.Server, token *http.Cookie, path string, body string) error {
+	request, err := http.NewRequest("POST", server.URL+path, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.AddCookie(token)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("POST %s: %s", path, response.Status)
+	}
+	return nil
+}
+
+func getQuestion(t *testing.T, server *httptest.Server, id string) model.Question {
+	t.Helper()
+
+	response, err := http.Get(server.URL + "/questions/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var result struct {
+		Data model.Question `json:"data"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	return result.Data
+}
+
+// Every voter fires a burst of parallel votes at the question and at its answer, cycling through
+// up, down and none. Whatever order they land in, the counts must match the votes left behind,
+// which the final vote of every voter pins down
+func TestParallelVotes(t *testing.T) {
+	const voters = 8
+	const votesPerVoter = 30
+
+	server := newTestServer(t)
+	author := login(t, server, "author")
+
+	var question struct {
+		Data model.Question `json:"data"`
+	}
+	post(t, server, author, "/user/question", `{"title":"Parallel votes","content":"How many votes?"}`, &question)
+	questionID := question.Data.ID.Hex()
+
+	var answer struct {
+		Data model.Answer `json:"data"`
+	}
+	post(t, server, author, "/questions/"+questionID+"/answers", `{"answer":"Exactly one per voter"}`, &answer)
+	answerID := answer.Data.ID.Hex()
+
+	paths := map[string]string{
+		"question": "/user/question/vote",
+		"answer":   "/questions/" + questionID + "/answers/" + answerID + "/vote",
+	}
+
+	voterTokens := make([]*http.Cookie, voters)
+	for i := range voterTokens {
+		voterTokens[i] = login(t, server, fmt.Sprintf("voter%d", i))
+	}
+
+	voteTypes := []string{"upvote", "downvote", "none"}
+	var wg sync.WaitGroup
+	for _, token := range voterTokens {
+		for j := 0; j < votesPerVoter; j++ {
+			wg.Add(1)
+			go func(token *http.Cookie, voteType string) {
+				defer wg.Done()
+				if err := vote(server, token, paths["question"], fmt.Sprintf(`{"questionid":%q,"votetype":%q}`, questionID, voteType)); err != nil {
+					t.Error(err)
+				}
+				if err := vote(server, token, paths["answer"], fmt.Sprintf(`{"votetype":%q}`, voteType)); err != nil {
+					t.Error(err)
+				}
+			}(token, voteTypes[j%len(voteTypes)])
+		}
+	}
+	wg.Wait()
+
+	// Repeated parallel upvotes from every voter count once each
+	for _, token := range voterTokens {
+		for j := 0; j < 3; j++ {
+			wg.Add(1)
+			go func(token *http.Cookie) {
+				defer wg.Done()
+				if err := vote(server, token, paths["question"], fmt.Sprintf(`{"questionid":%q,"votetype":"upvote"}`, questionID)); err != nil {
+					t.Error(err)
+				}
+				if err := vote(server, token, paths["answer"], `{"votetype":"upvote"}`); err != nil {
+					t.Error(err)
+				}
+			}(token)
+		}
+	}
+	wg.Wait()
+
+	result := getQuestion(t, server, questionID)
+	if result.Votes != voters {
+		t.Errorf("question has %d votes, want %d", result.Votes, voters)
+	}
+	if len(result.Answers) != 1 || result.Answers[0].Votes != voters {
+		t.Errorf("answer votes = %+v, want %d", result.Answers, voters)
+	}
+}
+
+// The reputation of the user as stored and as the ledger adds up to again
+func reputationOf(t *testing.T, server *httptest.Server, moderator *http.Cookie, username string) (int, int) {
+	t.Helper()
+
+	var result struct {
+		Data struct {
+			Previous   int `json:"previous"`
+			Reputation int `json:"reputation"`
+		} `json:"data"`
+	}
+	post(t, server, moderator, "/users/"+username+"/reputation/recompute", "", &result)
+	return result.Data.Previous, result.Data.Reputation
+}
+
+// Deleting an answer takes back what its votes and being accepted earned its author, undeleting it
+// gives back what the votes earn
+func TestDeletedAnswerReputation(t *testing.T) {
+	server := newTestServer(t)
+	asker, answerer, moderator := login(t, server, "asker"), login(t, server, "answerer"), login(t, server, "moderator")
+
+	var question struct {
+		Data model.Question `json:"data"`
+	}
+	post(t, server, asker, "/user/question", `{"title":"Deleted answers","content":"Who keeps the points?"}`, &question)
+	var answer struct {
+		Data model.Answer `json:"data"`
+	}
+	questionPath := "/questions/" + question.Data.ID.Hex()
+	post(t, server, answerer, questionPath+"/answers", `{"answer":"Nobody"}`, &answer)
+	answerPath := questionPath + "/answers/" + answer.Data.ID.Hex()
+
+	for i, voteType := range []string{"upvote", "upvote", "downvote"} {
+		if err := vote(server, login(t, server, fmt.Sprint("voter", i)), answerPath+"/vote", fmt.Sprintf(`{"votetype":%q}`, voteType)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if response := post(t, server, asker, answerPath+"/accept", "", nil); response.StatusCode != http.StatusOK {
+		t.Fatalf("accept: %s", response.Status)
+	}
+	if stored, replayed := reputationOf(t, server, moderator, "answerer"); stored != 33 || replayed != 33 {
+		t.Fatalf("reputation before the deletion: %d stored, %d replayed, want 33", stored, replayed)
+	}
+
+	if response := send(t, server, answerer, "DELETE", answerPath, "", nil); response.StatusCode != http.StatusOK {
+		t.Fatalf("delete: %s", response.Status)
+	}
+	if stored, replayed := reputationOf(t, server, moderator, "answerer"); stored != 0 || replayed != 0 {
+		t.Errorf("reputation after the deletion: %d stored, %d replayed, want 0", stored, replayed)
+	}
+
+	// The votes count again, the answer is no longer accepted
+	if response := post(t, server, moderator, answerPath+"/undelete", "", nil); response.StatusCode != http.StatusOK {
+		t.Fatalf("undelete: %s", response.Status)
+	}
+	if stored, replayed := reputationOf(t, server, moderator, "answerer"); stored != 18 || replayed != 18 {
+		t.Errorf("reputation after the undeletion: %d stored, %d replayed, want 18", stored, replayed)
+	}
+}
+
+// Users without the reputation a privilege needs are refused with a 403 naming it. Their own posts and
+// the moderators are exempt
+func TestPrivilegeRefusals(t *testing.T) {
+	QAEngineConfig := config.Default()
+	QAEngineConfig.Privileges = map[string]int{
+		config.PrivilegeComment:   5,
+		config.PrivilegeDownvote:  10,
+		config.PrivilegeReview:    15,
+		config.PrivilegeEdit:      20,
+		config.PrivilegeCloseVote: 25,
+	}
+	server := newTestServerWithConfig(t, QAEngineConfig)
+	asker, newbie, moderator := login(t, server, "asker"), login(t, server, "newbie"), login(t, server, "moderator")
+
+	var question struct {
+		Data model.Question `json:"data"`
+	}
+	post(t, server, asker, "/user/question", `{"title":"Privileges","content":"Who can do what?"}`, &question)
+	questionID := question.Data.ID.Hex()
+	questionPath := "/questions/" + questionID
+	downvote := fmt.Sprintf(`{"questionid":%q,"votetype":"downvote"}`, questionID)
+
+	requests := []struct {
+		method, path, body, privilege string
+	}{
+		{"POST", "/user/question/vote", downvote, config.PrivilegeDownvote},
+		{"POST", questionPath + "/comments", `{"text":"A comment"}`, config.PrivilegeComment},
+		{"PUT", questionPath, `{"title":"Privileges edited"}`, config.PrivilegeEdit},
+		{"GET", "/review/close", "", config.PrivilegeReview},
+		{"POST", questionPath + "/close-votes", `{"reason":"unclear"}`, config.PrivilegeCloseVote},
+	}
+	for _, r := range requests {
+		var refusal middlewares.MissingPrivilege
+		response := send(t, server, newbie, r.method, r.path, r.body, &refusal)
+		want := middlewares.MissingPrivilege{
+			Err:       true,
+			Message:   fmt.Sprintf("The %s privilege needs %d reputation", r.privilege, QAEngineConfig.Privileges[r.privilege]),
+			Privilege: r.privilege,
+			Required:  QAEngineConfig.Privileges[r.privilege],
+		}
+		if response.StatusCode != http.StatusForbidden || refusal != want {
+			t.Errorf("%s %s: %s %+v, want 403 %+v", r.method, r.path, response.Status, refusal, want)
+		}
+		// Moderators hold every privilege
+		if response = send(t, server, moderator, r.method, r.path, r.body, nil); response.StatusCode == http.StatusForbidden {
+			t.Errorf("%s %s refused to a moderator", r.method, r.path)
+		}
+	}
+
+	// Upvotes need no privilege, and authors comment on and edit their own posts
+	if err := vote(server, newbie, "/user/question/vote", fmt.Sprintf(`{"questionid":%q,"votetype":"upvote"}`, questionID)); err != nil {
+		t.Error(err)
+	}
+	var own struct {
+		Data model.Question `json:"data"`
+	}
+	post(t, server, newbie, "/user/question", `{"title":"My own question","content":"Mine"}`, &own)
+	ownPath := "/questions/" + own.Data.ID.Hex()
+	if response := post(t, server, newbie, ownPath+"/comments", `{"text":"My comment"}`, nil); response.StatusCode != http.StatusOK {
+		t.Errorf("comment on an own question: %s", response.Status)
+	}
+	if response := send(t, server, newbie, "PUT", ownPath, `{"title":"My own question edited"}`, nil); response.StatusCode != http.StatusOK {
+		t.Errorf("edit of an own question: %s", response.Status)
+	}
+
+	// An upvote earns the comment privilege, the downvote one stays out of reach
+	if err := vote(server, asker, "/user/question/vote", fmt.Sprintf(`{"questionid":%q,"votetype":"upvote"}`, own.Data.ID.Hex())); err != nil {
+		t.Fatal(err)
+	}
+	if response := post(t, server, newbie, questionPath+"/comments", `{"text":"Now I can"}`, nil); response.StatusCode != http.StatusOK {
+		t.Errorf("comment with 5 reputation: %s", response.Status)
+	}
+	var refusal middlewares.MissingPrivilege
+	if response := post(t, server, newbie, "/user/question/vote", downvote, &refusal); response.StatusCode != http.StatusForbidden || refusal.Reputation != 5 {
+		t.Errorf("downvote with 5 reputation: %s %+v", response.Status, refusal)
+	}
+}
+
+// A question closed as a duplicate can be pointed at another question, but not closed again as it is
+func TestRetargetDuplicate(t *testing.T) {
+	server := newTestServer(t)
+	asker := login(t, server, "asker")
+
+	ids := make([]string, 3)
+	for i := range ids {
+		var question struct {
+			Data model.Question `json:"data"`
+		}
+		post(t, server, asker, "/user/question", fmt.Sprintf(`{"title":"Question %d","content":"Asked again"}`, i), &question)
+		ids[i] = question.Data.ID.Hex()
+	}
+	statePath := "/questions/" + ids[2] + "/state"
+	closeAs := func(duplicateOf string) *http.Response {
+		return post(t, server, asker, statePath, fmt.Sprintf(`{"state":"closed","reason":"duplicate","duplicateof":%q}`, duplicateOf), nil)
+	}
+
+	if response := closeAs(ids[0]); response.StatusCode != http.StatusOK {
+		t.Fatalf("close as a duplicate: %s", response.Status)
+	}
+	if response := closeAs(ids[0]); response.StatusCode != http.StatusConflict {
+		t.Errorf("close as a duplicate of the same question again: %s, want 409", response.Status)
+	}
+	if response := closeAs(ids[1]); response.StatusCode != http.StatusOK {
+		t.Fatalf("close as a duplicate of another question: %s", response.Status)
+	}
+	if status := getQuestion(t, server, ids[2]).Status; status.DuplicateOf == nil || status.DuplicateOf.Hex() != ids[1] {
+		t.Errorf("status = %+v, want a duplicate of %s", status, ids[1])
+	}
+}
+
+// A tag merge goes into the history of the retagged questions as a revision of the moderator, and
+// the next edit only changes what its editor changed
+func TestMergeRevision(t *testing.T) {
+	server := newTestServer(t)
+	asker, editor, moderator := login(t, server, "asker"), login(t, server, "editor"), login(t, server, "moderator")
+
+	var question struct {
+		Data model.Question `json:"data"`
+	}
+	post(t, server, asker, "/user/question", `{"title":"Merged tags","content":"Whose change?","tags":["golang","json"]}`, &question)
+	questionPath := "/questions/" + question.Data.ID.Hex()
+	if response := send(t, server, editor, "PUT", questionPath, `{"content":"Whose change is it?"}`, nil); response.StatusCode != http.StatusOK {
+		t.Fatalf("edit before the merge: %s", response.Status)
+	}
+
+	if response := post(t, server, moderator, "/tags/golang/merge", `{"into":"go"}`, nil); response.StatusCode != http.StatusOK {
+		t.Fatalf("merge: %s", response.Status)
+	}
+	if response := send(t, server, editor, "PUT", questionPath, `{"title":"Merged tags, edited"}`, nil); response.StatusCode != http.StatusOK {
+		t.Fatalf("edit after the merge: %s", response.Status)
+	}
+
+	var revisions struct {
+		Data []model.Revision `json:"data"`
+	}
+	send(t, server, nil, "GET", questionPath+"/revisions", "", &revisions)
+	if len(revisions.Data) != 4 {
+		t.Fatalf("revisions = %+v, want the question as asked, the two edits and the merge", revisions.Data)
+	}
+	merge, edit := revisions.Data[2], revisions.Data[3]
+	if fmt.Sprint(revisions.Data[1].Tags) != "[golang json]" {
+		t.Errorf("revision 2 tags = %v, want [golang json]", revisions.Data[1].Tags)
+	}
+	if merge.Editor != "moderator" || fmt.Sprint(merge.Changed) != "[tags]" || fmt.Sprint(merge.Tags) != "[go json]" {
+		t.Errorf("revision 3 = %+v, want the moderator retagging to [go json]", merge)
+	}
+	if edit.Editor != "editor" || fmt.Sprint(edit.Changed) != "[title]" {
+		t.Errorf("revision 4 = %+v, want the editor changing the title only", edit)
+	}
+}
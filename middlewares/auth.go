@@ -5,46 +5,73 @@ import (
 	"net/http"
 
 	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
 	"github.com/dgrijalva/jwt-go"
 )
 
-func VerifyRequest(response http.ResponseWriter, request *http.Request) error {
+// VerifyRequest checks the token cookie of the request against the configured keys and the revocation
+// list, and returns the claims of the logged in user
+func VerifyRequest(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager) (*model.Claims, error) {
+	claims, status, err := verifyToken(request, QAEngineStore, QAEngineKeys)
+	if err != nil {
+		response.WriteHeader(status)
+		return nil, err
+	}
+	return claims, nil
+}
+
+// OptionalClaims returns the claims of the logged in user, or nil for anonymous requests and requests
+// whose token does not verify. Unlike VerifyRequest it never writes to the response
+func OptionalClaims(request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager) *model.Claims {
+	claims, _, err := verifyToken(request, QAEngineStore, QAEngineKeys)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
+
+// Verifies the token cookie, returning the status code to answer with when it does not verify
+func verifyToken(request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager) (*model.Claims, int, error) {
 	c, err := request.Cookie("token")
 
 	if err != nil {
 		if err == http.ErrNoCookie {
 			// Cookie not present in the request
-			response.WriteHeader(http.StatusUnauthorized)
-			return errors.New(err.Error())
+			return nil, http.StatusUnauthorized, errors.New(err.Error())
 		}
-		response.WriteHeader(http.StatusBadRequest)
-		return errors.New(err.Error())
-		
-		
+		return nil, http.StatusBadRequest, errors.New(err.Error())
+
+
 	} else {
 		tokenString := c.Value
 
 		claims := &model.Claims{}
 
 
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return token, nil
-		})
+		token, err := QAEngineKeys.Parse(tokenString, claims)
 
 		if err != nil {
-			if err == jwt.ErrSignatureInvalid {
-				response.WriteHeader(http.StatusUnauthorized)
-				return errors.New("Unauthorized Access")
+			if validationError, ok := err.(*jwt.ValidationError); ok && validationError.Errors&jwt.ValidationErrorMalformed != 0 {
+				return nil, http.StatusBadRequest, errors.New("Bad Request")
 			}
-			response.WriteHeader(http.StatusBadRequest)
-			return errors.New("Bad Request")
+			// Bad signature, unknown key or expired token
+			return nil, http.StatusUnauthorized, errors.New("Unauthorized Access")
 		}
-		if !token.Valid {
-			response.WriteHeader(http.StatusUnauthorized)
-			return errors.New("Unauthorized Access")
+		if !token.Valid || claims.Id == "" {
+			return nil, http.StatusUnauthorized, errors.New("Unauthorized Access")
 		}
 
-		return nil
-		
+		// Tokens ended by a logout stay valid cryptographically until they expire
+		revoked, err := QAEngineStore.Tokens.IsRevoked(request.Context(), claims.Id)
+		if err != nil {
+			return nil, http.StatusInternalServerError, errors.New("Internal Server Error")
+		}
+		if revoked {
+			return nil, http.StatusUnauthorized, errors.New("Token has been revoked")
+		}
+
+		return claims, http.StatusOK, nil
+
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"example.org/config"
+	"example.org/model"
+	"example.org/store"
+	"example.org/tokens"
+)
+
+// MissingPrivilege is the error returned to users without the reputation a privilege needs.
+// It is meant to be encoded as the body of the 403 response
+type MissingPrivilege struct {
+	Err        bool   `json:"error"`
+	Message    string `json:"message"`
+	Privilege  string `json:"privilege"`
+	Required   int    `json:"required"`
+	Reputation int    `json:"reputation"`
+}
+
+func (missing *MissingPrivilege) Error() string {
+	return missing.Message
+}
+
+// CheckPrivilege returns a *MissingPrivilege when the logged in user lacks the reputation for the privilege.
+// Other errors come from reading the user
+func CheckPrivilege(ctx context.Context, QAEngineStore *store.Store, QAEngineConfig *config.Config, claims *model.Claims, privilege string) error {
+	if QAEngineConfig.IsModerator(claims.Username) {
+		return nil
+	}
+	user, err := QAEngineStore.Users.FindByUsername(ctx, claims.Username)
+	if err != nil {
+		return errors.New("Internal Server Error")
+	}
+	if QAEngineConfig.HasPrivilege(user.Username, user.Reputation, privilege) {
+		return nil
+	}
+
+	required := QAEngineConfig.Privileges[privilege]
+	return &MissingPrivilege{
+		Err:        true,
+		Message:    "The " + privilege + " privilege needs " + strconv.Itoa(required) + " reputation",
+		Privilege:  privilege,
+		Required:   required,
+		Reputation: user.Reputation,
+	}
+}
+
+// VerifyPrivilege verifies the request like VerifyRequest, then checks that the logged in user holds
+// the privilege. Users without it get a 403 and a *MissingPrivilege error
+func VerifyPrivilege(response http.ResponseWriter, request *http.Request, QAEngineStore *store.Store, QAEngineKeys *tokens.KeyManager, QAEngineConfig *config.Config, privilege string) (*model.Claims, error) {
+	claims, err := VerifyRequest(response, request, QAEngineStore, QAEngineKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	err = CheckPrivilege(request.Context(), QAEngineStore, QAEngineConfig, claims, privilege)
+	if _, missing := err.(*MissingPrivilege); missing {
+		response.WriteHeader(http.StatusForbidden)
+		return nil, err
+	} else if err != nil {
+		response.WriteHeader(http.StatusInternalServerError)
+		return nil, err
+	}
+	return claims, nil
+}
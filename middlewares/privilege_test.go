@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"context"
+	"testing"
+
+	"example.org/config"
+	"example.org/model"
+	"example.org/store"
+)
+
+func TestCheckPrivilege(t *testing.T) {
+	QAEngineStore := store.NewMemoryStore()
+	QAEngineConfig := config.Default()
+	QAEngineConfig.Moderators = []string{"mod"}
+	for _, username := range []string{"newbie", "mod"} {
+		user := model.UserModel{Username: username, Email: username + "@example.org"}
+		if err := QAEngineStore.Users.Insert(context.Background(), &user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := QAEngineStore.Users.AddReputation(context.Background(), "newbie", 49); err != nil {
+		t.Fatal(err)
+	}
+
+	err := CheckPrivilege(context.Background(), QAEngineStore, &QAEngineConfig, &model.Claims{Username: "newbie"}, config.PrivilegeComment)
+	missing, ok := err.(*MissingPrivilege)
+	if !ok {
+		t.Fatalf("49 reputation for the comment privilege: err = %v, want a *MissingPrivilege", err)
+	}
+	want := MissingPrivilege{Err: true, Message: "The comment privilege needs 50 reputation", Privilege: config.PrivilegeComment, Required: 50, Reputation: 49}
+	if *missing != want {
+		t.Errorf("missing privilege = %+v, want %+v", *missing, want)
+	}
+
+	if err = QAEngineStore.Users.AddReputation(context.Background(), "newbie", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = CheckPrivilege(context.Background(), QAEngineStore, &QAEngineConfig, &model.Claims{Username: "newbie"}, config.PrivilegeComment); err != nil {
+		t.Errorf("50 reputation for the comment privilege: %v", err)
+	}
+	if err = CheckPrivilege(context.Background(), QAEngineStore, &QAEngineConfig, &model.Claims{Username: "mod"}, config.PrivilegeCloseVote); err != nil {
+		t.Errorf("moderator without reputation: %v", err)
+	}
+
+	// A user who cannot be read is not refused for a missing privilege
+	err = CheckPrivilege(context.Background(), QAEngineStore, &QAEngineConfig, &model.Claims{Username: "ghost"}, config.PrivilegeComment)
+	if _, missing := err.(*MissingPrivilege); err == nil || missing {
+		t.Errorf("unknown user: err = %v, want another error", err)
+	}
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comment is a short remark on a question or on one of its answers, kept apart from the answers
+type Comment struct {
+	ID primitive.ObjectID `json:"commentid" bson:"_id,omitempty"`
+	QuestionID primitive.ObjectID `json:"questionid" bson:"questionid"`
+	// Zero for the comments on the question itself
+	AnswerID primitive.ObjectID `json:"answerid" bson:"answerid"`
+	// The comment this one replies to, on the same post, nil for the comments starting a thread
+	ReplyTo *primitive.ObjectID `json:"replyto,omitempty" bson:"replyto,omitempty"`
+	Username string `json:"username" bson:"username"`
+	Text string `json:"text" bson:"text"`
+	// The users named as @username in the text
+	Mentions []string `json:"mentions" bson:"mentions"`
+	CreatedAt time.Time `json:"createdat" bson:"createdat"`
+	// Last time the author edited the comment, zero if never
+	UpdatedAt time.Time `json:"updatedat" bson:"updatedat"`
+	Upvotes int `json:"upvotes" bson:"upvotes"`
+	// Who upvoted the comment, each user upvotes it at most once
+	Upvoters []string `json:"-" bson:"upvoters"`
+}
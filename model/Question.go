@@ -2,25 +2,60 @@ package model
 
 import (
 	"time"
-	
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Answer struct {
-	UserID string `json:"userid" bson:"userid`
+	ID primitive.ObjectID `json:"answerid" bson:"id"`
+	UserID string `json:"userid" bson:"userid"`
 	Username string `json:"username" bson:"username"`
 	Answer string `json:"answer" bson:"answer"`
 	ISSelected bool `json:"isselected" bson:"isselected"`
 	DatePosted time.Time `json:"dateposted" bson:"dateposted"`
 	Votes int `json:"votes" bson:"votes"`
 	Email string `json:"email" bson:"email"`
+	// Reputation of the author, only filled in for the payloads
+	Reputation int `json:"reputation" bson:"-"`
+	// Last time the author edited the answer, zero if never
+	UpdatedAt time.Time `json:"updatedat" bson:"updatedat"`
+	// Deleted answers stay in place as tombstones, hidden from everyone but the moderators
+	Deleted bool `json:"deleted" bson:"deleted"`
+	DeletedBy string `json:"deletedby,omitempty" bson:"deletedby,omitempty"`
+	DeletedAt time.Time `json:"deletedat" bson:"deletedat"`
+	// Only filled in for the question detail, the comments are stored apart
+	Comments []Comment `json:"comments,omitempty" bson:"-"`
 }
 
 type Question struct {
+	ID primitive.ObjectID `json:"questionid" bson:"_id,omitempty"`
+	CreatedAt time.Time `json:"createdat" bson:"createdat"`
+	// Time of the last edit of the question itself, the creation time until it is edited
+	UpdatedAt time.Time `json:"updatedat" bson:"updatedat"`
+	// Time of the last answer, vote or accepted answer on the question, or of its last edit
+	LastActivityAt time.Time `json:"lastactivityat" bson:"lastactivityat"`
 	Username string `json:"username" bson:"username"`
+	// Reputation of the author, only filled in for the payloads
+	Reputation int `json:"reputation" bson:"-"`
 	Title string `json:"title" bson:"title"`
 	Content string `json:"content" bson:"content"`
+	Tags []string `json:"tags" bson:"tags"`
 	Answers []Answer `json:"answers" bson:"answers"`
 	SelectedAnswer Answer `json:"selectedanswer" bson:"selectedanswer"`
 	Votes int `json:"votes" bson:"votes"`
+	Status QuestionStatus `json:"status" bson:"status"`
+	// Pending votes to close the question, cleared whenever its state changes
+	CloseVotes []CloseVote `json:"closevotes,omitempty" bson:"closevotes,omitempty"`
+	// Only filled in for the question detail, the comments are stored apart
+	Comments []Comment `json:"comments,omitempty" bson:"-"`
+
+	// Kept up to date by the store so the listings can sort on them
+	Upvotes int `json:"upvotes" bson:"upvotes"`
+	Downvotes int `json:"downvotes" bson:"downvotes"`
+	Controversy float64 `json:"controversy" bson:"controversy"`
+	Hot float64 `json:"hot" bson:"hot"`
+	AnswerCount int `json:"answercount" bson:"answercount"`
+	// Zero while the question has no answers
+	LastAnswerAt time.Time `json:"lastanswerat" bson:"lastanswerat"`
 }
 
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// States of a question
+const (
+	QuestionOpen = "open"
+	// Closed questions take no new answers
+	QuestionClosed = "closed"
+	// Locked questions cannot be changed at all, not even voted on
+	QuestionLocked = "locked"
+	// Deleted questions are hidden from everyone but the moderators
+	QuestionDeleted = "deleted"
+)
+
+// QuestionStates lists every state of a question
+var QuestionStates = []string{QuestionOpen, QuestionClosed, QuestionLocked, QuestionDeleted}
+
+// Reasons a question is closed for
+const (
+	CloseDuplicate = "duplicate"
+	CloseOffTopic = "off-topic"
+	CloseUnclear = "unclear"
+	CloseTooBroad = "too-broad"
+	CloseOpinionBased = "opinion-based"
+)
+
+// CloseReasons lists every reason a question can be closed for
+var CloseReasons = []string{CloseDuplicate, CloseOffTopic, CloseUnclear, CloseTooBroad, CloseOpinionBased}
+
+// QuestionStatus is the state of a question and who put it there
+type QuestionStatus struct {
+	State string `json:"state" bson:"state"`
+	// Only set while the question is closed
+	CloseReason string `json:"closereason,omitempty" bson:"closereason,omitempty"`
+	// The question this one duplicates, only set when it is closed as a duplicate
+	DuplicateOf *primitive.ObjectID `json:"duplicateof,omitempty" bson:"duplicateof,omitempty"`
+	// Empty until the state is first changed
+	ChangedBy string `json:"changedby,omitempty" bson:"changedby,omitempty"`
+	ChangedAt time.Time `json:"changedat" bson:"changedat"`
+}
+
+// CloseVote is the vote of a user to close an open question, enough of them close it
+type CloseVote struct {
+	Username string `json:"username" bson:"username"`
+	Reason string `json:"reason" bson:"reason"`
+	// The question the voter takes this one for a duplicate of, only set for the duplicate reason
+	DuplicateOf *primitive.ObjectID `json:"duplicateof,omitempty" bson:"duplicateof,omitempty"`
+	CreatedAt time.Time `json:"createdat" bson:"createdat"`
+}
+
+// CurrentState returns the state of the question, questions stored before states existed are open
+func (question Question) CurrentState() string {
+	if question.Status.State == "" {
+		return QuestionOpen
+	}
+	return question.Status.State
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Why the reputation of a user changed
+const (
+	ReputationQuestionUpvoted = "question-upvoted"
+	ReputationQuestionDownvoted = "question-downvoted"
+	ReputationAnswerUpvoted = "answer-upvoted"
+	ReputationAnswerDownvoted = "answer-downvoted"
+	ReputationAnswerAccepted = "answer-accepted"
+)
+
+// ReputationEntry is one line of the reputation ledger, the points a user earned or lost through what
+// another user did to one of their posts. Entries are never changed, retracting a vote or an accepted
+// answer adds an entry taking back the points of the entry it undoes
+type ReputationEntry struct {
+	ID primitive.ObjectID `json:"entryid" bson:"_id,omitempty"`
+	// The user whose reputation changed
+	Username string `json:"username" bson:"username"`
+	Reason string `json:"reason" bson:"reason"`
+	// The user who voted or accepted the answer
+	Actor string `json:"actor" bson:"actor"`
+	QuestionID primitive.ObjectID `json:"questionid" bson:"questionid"`
+	// Zero when the question itself was voted on
+	AnswerID primitive.ObjectID `json:"answerid" bson:"answerid"`
+	// What the entry added to the reputation, after the daily cap
+	Points int `json:"points" bson:"points"`
+	// The entry this one takes back, nil for the entries awarding points
+	Undoes *primitive.ObjectID `json:"undoes,omitempty" bson:"undoes,omitempty"`
+	CreatedAt time.Time `json:"createdat" bson:"createdat"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Fields of a post a revision can change
+const (
+	RevisionTitle   = "title"
+	RevisionContent = "content"
+	RevisionTags    = "tags"
+)
+
+// Revision is the state of a question, or of one of its answers, after an edit.
+// Revision 1 is the post as it was first written, every later one records who changed what and why
+type Revision struct {
+	ID primitive.ObjectID `json:"revisionid" bson:"_id,omitempty"`
+	QuestionID primitive.ObjectID `json:"questionid" bson:"questionid"`
+	// Zero for the revisions of the question itself
+	AnswerID primitive.ObjectID `json:"answerid" bson:"answerid"`
+	Number int `json:"number" bson:"number"`
+	Editor string `json:"editor" bson:"editor"`
+	Comment string `json:"comment" bson:"comment"`
+	CreatedAt time.Time `json:"createdat" bson:"createdat"`
+	// The fields that differ from the previous revision
+	Changed []string `json:"changed" bson:"changed"`
+	// The earlier revision this one restores, 0 unless it is a rollback
+	RollbackOf int `json:"rollbackof,omitempty" bson:"rollbackof,omitempty"`
+
+	// Title and tags are only set on the revisions of questions
+	Title string `json:"title,omitempty" bson:"title,omitempty"`
+	Content string `json:"content" bson:"content"`
+	Tags []string `json:"tags,omitempty" bson:"tags,omitempty"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+)
+
+// Tag describes a tag questions can be filed under. It is created the first time a question uses it
+type Tag struct {
+	Name string `json:"name" bson:"_id"`
+	// Short summary shown next to the tag, the wiki holds the full text
+	Excerpt string `json:"excerpt" bson:"excerpt"`
+	Wiki string `json:"wiki" bson:"wiki"`
+	// Last editor of the excerpt and wiki, empty until the first edit
+	EditedBy string `json:"editedby" bson:"editedby"`
+	EditedAt time.Time `json:"editedat" bson:"editedat"`
+	CreatedAt time.Time `json:"createdat" bson:"createdat"`
+}
+
+// TagCount is the number of questions filed under a tag
+type TagCount struct {
+	Name string `json:"name" bson:"_id"`
+	Count int `json:"count" bson:"count"`
+}
+
+// TagStats sums up how a tag is used
+type TagStats struct {
+	Questions int `json:"questions" bson:"questions"`
+	Unanswered int `json:"unanswered" bson:"unanswered"`
+	Answers int `json:"answers" bson:"answers"`
+	Votes int `json:"votes" bson:"votes"`
+	// Questions asked under the tag in the last seven days
+	QuestionsThisWeek int `json:"questionsthisweek" bson:"questionsthisweek"`
+	// Zero when no question uses the tag
+	LastUsedAt time.Time `json:"lastusedat" bson:"lastusedat"`
+}
+
+// The states of a tag synonym. Only approved synonyms are applied
+const (
+	SynonymPending = "pending"
+	SynonymApproved = "approved"
+	SynonymRejected = "rejected"
+)
+
+// TagSynonym maps a tag onto the one questions should be filed under, golang onto go for instance.
+// A tag is the source of at most one synonym
+type TagSynonym struct {
+	Source string `json:"source" bson:"_id"`
+	Target string `json:"target" bson:"target"`
+	Status string `json:"status" bson:"status"`
+	ProposedBy string `json:"proposedby" bson:"proposedby"`
+	ProposedAt time.Time `json:"proposedat" bson:"proposedat"`
+	// The moderator who approved or rejected the synonym, empty while it is pending
+	ReviewedBy string `json:"reviewedby" bson:"reviewedby"`
+	ReviewedAt time.Time `json:"reviewedat" bson:"reviewedat"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// RefreshToken is the server side record of a refresh token. Only the hash of the token is stored.
+// Every refresh token issued from the same login shares a Family, so a reused token can end the whole session
+type RefreshToken struct {
+	ID string `json:"id" bson:"_id"`
+	Family string `json:"family" bson:"family"`
+	Username string `json:"username" bson:"username"`
+	Email string `json:"email" bson:"email"`
+	ExpiresAt time.Time `json:"expiresat" bson:"expiresat"`
+	Used bool `json:"used" bson:"used"`
+}
+
+// RevokedToken is an access token that was revoked before it expired, identified by its jti
+type RevokedToken struct {
+	ID string `json:"jti" bson:"_id"`
+	ExpiresAt time.Time `json:"expiresat" bson:"expiresat"`
+}
@@ -23,6 +23,8 @@ type UserReturnModel struct {
 	Country string `json:"country" bson:"country"`
 	Phone int64 `json:"phone" bson:"phone"`
 	City string `json:"city" bson:"city"`
+	// Kept equal to the sum of the reputation ledger of the user
+	Reputation int `json:"reputation" bson:"reputation"`
 }
 
 type UserLogin struct {
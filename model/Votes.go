@@ -5,15 +5,43 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// VoteDoc is a single vote in the history of a user. Votes on answers also carry the id of the answer
 type VoteDoc struct {
+	QuestionID primitive.ObjectID `json:"questionid" bson:"questionid"`
+	AnswerID primitive.ObjectID `json:"answerid,omitempty" bson:"answerid,omitempty"`
 	Title string    `json:"title" bson:"title"`
 	Date  time.Time `json:"upvoteTime" bson:"upvoteTime"`
 }
 
+// Votes is the legacy per user vote history. It is only read to migrate it to Vote records
 type Votes struct {
-	ID primitive.ObjectID `json:"userId" bson:"_id"`
+	ID primitive.ObjectID `json:"userId" bson:"_id,omitempty"`
 	Username string `json:"username" bson:"username"`
 	Email string `json:"email" bson:"email"`
 	Upvotes []VoteDoc `json:"upvotes" bson:"upvotes"`
 	Downvotes []VoteDoc `json:"downvotes" bson:"downvotes"`
-}
\ No newline at end of file
+}
+
+// The vote a user holds on a question or an answer
+const (
+	VoteUp = "up"
+	VoteDown = "down"
+	VoteNone = "none"
+)
+
+// VoteKey names the voter and the target of a vote. The AnswerID is zero for votes on the question itself.
+// It is the _id of the vote records, so a user can never hold two votes on the same target
+type VoteKey struct {
+	Username string `json:"username" bson:"username"`
+	QuestionID primitive.ObjectID `json:"questionid" bson:"questionid"`
+	AnswerID primitive.ObjectID `json:"answerid" bson:"answerid"`
+}
+
+// Vote is the vote a user currently holds on a question or an answer, up or down
+type Vote struct {
+	Key VoteKey `json:"key" bson:"_id"`
+	Email string `json:"email" bson:"email"`
+	Title string `json:"title" bson:"title"`
+	Vote string `json:"vote" bson:"vote"`
+	Date time.Time `json:"date" bson:"date"`
+}
@@ -0,0 +1,85 @@
+// Package reputation computes the points users earn for their questions and answers
+package reputation
+
+import (
+	"time"
+
+	"example.org/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var points = map[string]int{
+	model.ReputationQuestionUpvoted:   5,
+	model.ReputationQuestionDownvoted: -2,
+	model.ReputationAnswerUpvoted:     10,
+	model.ReputationAnswerDownvoted:   -2,
+	model.ReputationAnswerAccepted:    15,
+}
+
+// CappedReasons earn at most the daily cap together, the other reasons are never capped
+var CappedReasons = []string{model.ReputationQuestionUpvoted, model.ReputationAnswerUpvoted}
+
+// Points returns what the reason is worth before the daily cap
+func Points(reason string) int {
+	return points[reason]
+}
+
+// Capped reports whether the points of the reason count toward the daily cap
+func Capped(reason string) bool {
+	for _, capped := range CappedReasons {
+		if capped == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Day returns the start of the day of t, the caps reset at midnight UTC
+func Day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// Award returns the points a new entry for the reason earns, once earnedToday points of the
+// capped reasons were earned the same day
+func Award(reason string, earnedToday int, dailyCap int) int {
+	value := Points(reason)
+	if !Capped(reason) || value <= 0 {
+		return value
+	}
+	if room := dailyCap - earnedToday; value > room {
+		if room < 0 {
+			return 0
+		}
+		return room
+	}
+	return value
+}
+
+// Replay awards the entries of the ledger of a user again, oldest first, and returns the reputation
+// they add up to. It gives the same points as the entries were awarded with, unless the points or the
+// cap changed since, or concurrent votes overran the cap. An entry is only ever taken back once
+func Replay(entries []model.ReputationEntry, dailyCap int) int {
+	total := 0
+	awarded := map[primitive.ObjectID]int{}
+	undone := map[primitive.ObjectID]bool{}
+	earned := map[time.Time]int{}
+	for _, entry := range entries {
+		day := Day(entry.CreatedAt)
+
+		value := 0
+		if entry.Undoes != nil {
+			if !undone[*entry.Undoes] {
+				value = -awarded[*entry.Undoes]
+				undone[*entry.Undoes] = true
+			}
+		} else {
+			value = Award(entry.Reason, earned[day], dailyCap)
+		}
+		awarded[entry.ID] = value
+		if Capped(entry.Reason) {
+			earned[day] += value
+		}
+		total += value
+	}
+	return total
+}
@@ -0,0 +1,115 @@
+package reputation
+
+import (
+	"testing"
+	"time"
+
+	"example.org/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var morning = time.Date(2024, time.March, 1, 8, 0, 0, 0, time.UTC)
+
+func TestAward(t *testing.T) {
+	cases := []struct {
+		reason      string
+		earnedToday int
+		want        int
+	}{
+		{model.ReputationAnswerUpvoted, 0, 10},
+		{model.ReputationQuestionUpvoted, 0, 5},
+		// Only the room left under the cap is awarded
+		{model.ReputationAnswerUpvoted, 195, 5},
+		{model.ReputationAnswerUpvoted, 200, 0},
+		{model.ReputationAnswerUpvoted, 250, 0},
+		// Accepted answers and downvotes are never capped
+		{model.ReputationAnswerAccepted, 200, 15},
+		{model.ReputationAnswerDownvoted, 200, -2},
+		{model.ReputationQuestionDownvoted, 0, -2},
+		{"unknown", 0, 0},
+	}
+	for _, c := range cases {
+		if got := Award(c.reason, c.earnedToday, 200); got != c.want {
+			t.Errorf("Award(%s, %d) = %d, want %d", c.reason, c.earnedToday, got, c.want)
+		}
+	}
+}
+
+func TestDay(t *testing.T) {
+	late := time.Date(2024, time.March, 1, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+	if day := Day(late); !day.Equal(time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Day(%v) = %v, the day starts at midnight UTC", late, day)
+	}
+}
+
+// Builds ledger entries, the undo ones taking back the entry at the given index
+type ledger []model.ReputationEntry
+
+func (entries *ledger) add(reason string, at time.Time) {
+	*entries = append(*entries, model.ReputationEntry{ID: primitive.NewObjectID(), Reason: reason, CreatedAt: at})
+}
+
+func (entries *ledger) undo(index int, at time.Time) {
+	undone := (*entries)[index]
+	*entries = append(*entries, model.ReputationEntry{ID: primitive.NewObjectID(), Reason: undone.Reason, Undoes: &undone.ID, CreatedAt: at})
+}
+
+func TestReplay(t *testing.T) {
+	var entries ledger
+	entries.add(model.ReputationAnswerUpvoted, morning)
+	entries.add(model.ReputationQuestionUpvoted, morning)
+	entries.add(model.ReputationAnswerDownvoted, morning)
+	entries.add(model.ReputationAnswerAccepted, morning)
+	if got := Replay(entries, 200); got != 10+5-2+15 {
+		t.Errorf("Replay = %d, want %d", got, 10+5-2+15)
+	}
+
+	entries.undo(0, morning.Add(time.Hour))
+	entries.undo(2, morning.Add(time.Hour))
+	if got := Replay(entries, 200); got != 5+15 {
+		t.Errorf("Replay after undoing an upvote and a downvote = %d, want %d", got, 5+15)
+	}
+
+	// Undoing an entry twice, or one that never existed, takes nothing more back
+	entries.undo(0, morning.Add(2*time.Hour))
+	missing := primitive.NewObjectID()
+	entries = append(entries, model.ReputationEntry{ID: primitive.NewObjectID(), Reason: model.ReputationAnswerUpvoted, Undoes: &missing})
+	if got := Replay(entries, 200); got != 5+15 {
+		t.Errorf("Replay after undoing an entry twice = %d, want %d", got, 5+15)
+	}
+}
+
+// Upvotes stop earning at the daily cap and start again the next day. Undoing an upvote frees room
+// under the cap for the upvotes after it, and takes back only what the upvote earned
+func TestReplayDailyCap(t *testing.T) {
+	var entries ledger
+	for i := 0; i < 4; i++ {
+		entries.add(model.ReputationAnswerUpvoted, morning.Add(time.Duration(i)*time.Minute))
+	}
+	if got := Replay(entries, 25); got != 25 {
+		t.Fatalf("four upvotes under a cap of 25 = %d, want 25", got)
+	}
+
+	// The fourth earned nothing, so undoing it takes nothing back
+	entries.undo(3, morning.Add(time.Hour))
+	if got := Replay(entries, 25); got != 25 {
+		t.Errorf("after undoing the upvote over the cap = %d, want 25", got)
+	}
+	// The third earned the 5 left under the cap
+	entries.undo(2, morning.Add(time.Hour))
+	if got := Replay(entries, 25); got != 20 {
+		t.Errorf("after undoing the upvote reaching the cap = %d, want 20", got)
+	}
+	entries.add(model.ReputationAnswerUpvoted, morning.Add(2*time.Hour))
+	if got := Replay(entries, 25); got != 25 {
+		t.Errorf("an upvote after room was freed = %d, want 25", got)
+	}
+
+	// Accepted answers and downvotes do not count toward the cap, a new day starts from nothing
+	entries.add(model.ReputationAnswerAccepted, morning.Add(3*time.Hour))
+	entries.add(model.ReputationAnswerDownvoted, morning.Add(3*time.Hour))
+	entries.add(model.ReputationQuestionUpvoted, morning.Add(24*time.Hour))
+	if got := Replay(entries, 25); got != 25+15-2+5 {
+		t.Errorf("with an accepted answer, a downvote and an upvote the next day = %d, want %d", got, 25+15-2+5)
+	}
+}
@@ -0,0 +1,349 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"example.org/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The fields of a question that are searched and how much a match in each weighs
+const (
+	fieldTitle = iota
+	fieldContent
+	fieldAnswers
+	fieldCount
+)
+
+var fieldWeights = [fieldCount]float64{fieldTitle: 3, fieldContent: 1, fieldAnswers: 0.7}
+
+// BM25 parameters, k1 is how fast repeated terms stop adding up and b how much long fields are penalized
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Hit is a question matching a search along with its relevance
+type Hit struct {
+	ID    primitive.ObjectID `json:"id"`
+	Score float64            `json:"score"`
+	// Votes of the question, Search ranks equal scores by them
+	Votes int `json:"votes"`
+}
+
+// Before reports whether the hit ranks before the other one in search results, by score, then votes,
+// then newest first. The order is total, so a hit is a cursor into the results
+func (hit Hit) Before(other Hit) bool {
+	if hit.Score != other.Score {
+		return hit.Score > other.Score
+	}
+	if hit.Votes != other.Votes {
+		return hit.Votes > other.Votes
+	}
+	return hit.ID.Hex() > other.ID.Hex()
+}
+
+// The indexed copy of a question
+type document struct {
+	// The words of every field in order, answers are kept apart so phrases do not run from one into the next
+	words  [fieldCount][][]string
+	counts [fieldCount]map[string]int
+	length [fieldCount]int
+
+	tags     []string
+	username string
+	votes    int
+	answers  int
+	accepted bool
+	// The other questions referenced from the content or the answers
+	links []primitive.ObjectID
+}
+
+// Index is an inverted index of the questions, kept in memory and safe for concurrent use
+type Index struct {
+	mu        sync.RWMutex
+	documents map[primitive.ObjectID]*document
+	// The questions every word appears in
+	postings map[string]map[primitive.ObjectID]bool
+	// The summed length of every field over all the questions
+	totalLength [fieldCount]int
+	// The questions linking to every question
+	linkedFrom map[primitive.ObjectID]map[primitive.ObjectID]bool
+
+	// Related questions already worked out, dropped whenever the words or tags of a question change
+	cacheMu sync.Mutex
+	related map[primitive.ObjectID]Related
+}
+
+func NewIndex() *Index {
+	return &Index{
+		documents:  map[primitive.ObjectID]*document{},
+		postings:   map[string]map[primitive.ObjectID]bool{},
+		linkedFrom: map[primitive.ObjectID]map[primitive.ObjectID]bool{},
+		related:    map[primitive.ObjectID]Related{},
+	}
+}
+
+func newDocument(question model.Question) *document {
+	doc := &document{
+		tags:     question.Tags,
+		username: question.Username,
+		votes:    question.Votes,
+		accepted: !question.SelectedAnswer.ID.IsZero(),
+		links:    findLinks(question),
+	}
+	doc.words[fieldTitle] = [][]string{Tokenize(question.Title)}
+	doc.words[fieldContent] = [][]string{Tokenize(question.Content)}
+	// Deleted answers are not searchable
+	for _, answer := range question.Answers {
+		if !answer.Deleted {
+			doc.answers++
+			doc.words[fieldAnswers] = append(doc.words[fieldAnswers], Tokenize(answer.Answer))
+		}
+	}
+
+	for field, texts := range doc.words {
+		doc.counts[field] = map[string]int{}
+		for _, words := range texts {
+			doc.length[field] += len(words)
+			for _, word := range words {
+				if !IsStopWord(word) {
+					doc.counts[field][word]++
+				}
+			}
+		}
+	}
+	return doc
+}
+
+// Put indexes the question, replacing what was indexed for it before
+func (index *Index) Put(question model.Question) {
+	doc := newDocument(question)
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	// Votes and accepted answers do not change what is related, only words, tags and links do
+	if previous, found := index.documents[question.ID]; !found || !previous.sameText(doc) {
+		index.clearRelated()
+	}
+
+	index.remove(question.ID)
+	index.documents[question.ID] = doc
+	for _, link := range doc.links {
+		if index.linkedFrom[link] == nil {
+			index.linkedFrom[link] = map[primitive.ObjectID]bool{}
+		}
+		index.linkedFrom[link][question.ID] = true
+	}
+	for field := range doc.counts {
+		index.totalLength[field] += doc.length[field]
+		for word := range doc.counts[field] {
+			if index.postings[word] == nil {
+				index.postings[word] = map[primitive.ObjectID]bool{}
+			}
+			index.postings[word][question.ID] = true
+		}
+	}
+}
+
+// Remove drops the question from the index
+func (index *Index) Remove(id primitive.ObjectID) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.remove(id)
+	index.clearRelated()
+}
+
+func (index *Index) remove(id primitive.ObjectID) {
+	doc, found := index.documents[id]
+	if !found {
+		return
+	}
+	delete(index.documents, id)
+	for _, link := range doc.links {
+		delete(index.linkedFrom[link], id)
+		if len(index.linkedFrom[link]) == 0 {
+			delete(index.linkedFrom, link)
+		}
+	}
+	for field := range doc.counts {
+		index.totalLength[field] -= doc.length[field]
+		for word := range doc.counts[field] {
+			delete(index.postings[word], id)
+			if len(index.postings[word]) == 0 {
+				delete(index.postings, word)
+			}
+		}
+	}
+}
+
+// Tagged returns the questions filed under the tag
+func (index *Index) Tagged(tag string) []primitive.ObjectID {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	ids := []primitive.ObjectID{}
+	for id, doc := range index.documents {
+		if containsString(doc.tags, tag) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Search returns every question matching the query, the most relevant first.
+// Questions scoring the same, as all do when the query only has filters, are ordered by votes then newest first
+func (index *Index) Search(query Query) []Hit {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	words := query.Terms
+	for _, phrase := range query.Phrases {
+		for _, word := range phrase {
+			if !IsStopWord(word) {
+				words = append(words, word)
+			}
+		}
+	}
+
+	hits := []Hit{}
+	for id, doc := range index.candidates(words) {
+		if !index.matches(doc, query) {
+			continue
+		}
+		hits = append(hits, Hit{ID: id, Score: index.score(doc, words), Votes: doc.votes})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].Before(hits[j])
+	})
+	return hits
+}
+
+// The questions containing every word, every question when there are none
+func (index *Index) candidates(words []string) map[primitive.ObjectID]*document {
+	if len(words) == 0 {
+		return index.documents
+	}
+
+	// Walking the rarest word's postings keeps the intersection small
+	rarest := words[0]
+	for _, word := range words[1:] {
+		if len(index.postings[word]) < len(index.postings[rarest]) {
+			rarest = word
+		}
+	}
+
+	candidates := map[primitive.ObjectID]*document{}
+	for id := range index.postings[rarest] {
+		found := true
+		for _, word := range words {
+			if !index.postings[word][id] {
+				found = false
+				break
+			}
+		}
+		if found {
+			candidates[id] = index.documents[id]
+		}
+	}
+	return candidates
+}
+
+// Checks the phrases and the filters of the query against the question
+func (index *Index) matches(doc *document, query Query) bool {
+	for _, tag := range query.Tags {
+		if !containsString(doc.tags, tag) {
+			return false
+		}
+	}
+	if query.User != "" && !strings.EqualFold(doc.username, query.User) {
+		return false
+	}
+	if query.MinVotes != nil && doc.votes < *query.MinVotes {
+		return false
+	}
+	if query.MaxVotes != nil && doc.votes > *query.MaxVotes {
+		return false
+	}
+
+	switch query.Is {
+	case "answered":
+		if doc.answers == 0 {
+			return false
+		}
+	case "unanswered":
+		if doc.answers > 0 {
+			return false
+		}
+	case "accepted":
+		if !doc.accepted {
+			return false
+		}
+	}
+
+	for _, phrase := range query.Phrases {
+		if !doc.hasPhrase(phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+// BM25F, the term counts of the fields are weighted and normalized by field length before saturating
+func (index *Index) score(doc *document, words []string) float64 {
+	total := float64(len(index.documents))
+	score := 0.0
+	for _, word := range words {
+		frequency := 0.0
+		for field := range doc.counts {
+			count := doc.counts[field][word]
+			if count == 0 {
+				continue
+			}
+			average := float64(index.totalLength[field]) / total
+			norm := 1 - bm25B + bm25B*float64(doc.length[field])/average
+			frequency += fieldWeights[field] * float64(count) / norm
+		}
+
+		found := float64(len(index.postings[word]))
+		idf := math.Log(1 + (total-found+0.5)/(found+0.5))
+		score += idf * frequency / (bm25K1 + frequency)
+	}
+	return score
+}
+
+func (doc *document) hasPhrase(phrase []string) bool {
+	for _, texts := range doc.words {
+		for _, words := range texts {
+			for start := 0; start+len(phrase) <= len(words); start++ {
+				if equalWords(words[start:start+len(phrase)], phrase) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func equalWords(a []string, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
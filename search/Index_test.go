@@ -0,0 +1,169 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"example.org/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Indexes a question with the title, content and answers, returning its ID
+func put(index *Index, title string, content string, answers ...string) primitive.ObjectID {
+	question := model.Question{ID: primitive.NewObjectID(), Title: title, Content: content}
+	for _, answer := range answers {
+		question.Answers = append(question.Answers, model.Answer{ID: primitive.NewObjectID(), Answer: answer})
+	}
+	index.Put(question)
+	return question.ID
+}
+
+func search(t *testing.T, index *Index, text string) []Hit {
+	t.Helper()
+
+	query, err := ParseQuery(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return index.Search(query)
+}
+
+// Names the hits after the questions, so the order can be compared with the expected one
+func hitNames(hits []Hit, names map[primitive.ObjectID]string) string {
+	listed := []string{}
+	for _, hit := range hits {
+		listed = append(listed, names[hit.ID])
+	}
+	return fmt.Sprint(listed)
+}
+
+// Unrelated questions, so the words searched are rare enough to count
+func pad(index *Index) {
+	for i := 0; i < 10; i++ {
+		put(index, fmt.Sprintf("padding question %d", i), "nothing to see here at all")
+	}
+}
+
+// A match in the title outweighs one in the content, which outweighs one in an answer
+func TestFieldWeights(t *testing.T) {
+	index := NewIndex()
+	pad(index)
+	names := map[primitive.ObjectID]string{
+		put(index, "other question", "nothing to see here", "goroutine leak"): "answer",
+		put(index, "goroutine leak", "nothing to see here"):                   "title",
+		put(index, "other question", "goroutine leak here"):                   "content",
+	}
+
+	if got := hitNames(search(t, index, "goroutine leak"), names); got != "[title content answer]" {
+		t.Errorf("goroutine leak: %s, want [title content answer]", got)
+	}
+}
+
+// BM25 rewards repeated words less and less, favours short fields and weighs rare words more
+func TestBM25(t *testing.T) {
+	index := NewIndex()
+	pad(index)
+	once := put(index, "question", "the deadlock was found in a server")
+	often := put(index, "question", strings.Repeat("deadlock ", 10)+"found")
+	long := put(index, "question", "the deadlock was found in a server "+strings.Repeat("with many other words ", 10))
+	scores := map[primitive.ObjectID]float64{}
+	for _, hit := range search(t, index, "deadlock") {
+		scores[hit.ID] = hit.Score
+	}
+	if scores[often] <= scores[once] || scores[often] >= 10*scores[once] {
+		t.Errorf("ten times the word scores %v against %v, want more but far less than ten times", scores[often], scores[once])
+	}
+	if scores[long] >= scores[once] {
+		t.Errorf("a longer content scores %v, not below %v", scores[long], scores[once])
+	}
+
+	// Two questions of the same shape, one with a word no other question has and one with a word in three
+	rare := put(index, "question", "zygote")
+	common := put(index, "question", "server")
+	rareHit, commonHit := search(t, index, "zygote")[0], Hit{}
+	for _, hit := range search(t, index, "server") {
+		if hit.ID == common {
+			commonHit = hit
+		}
+	}
+	if rareHit.ID != rare || rareHit.Score <= commonHit.Score {
+		t.Errorf("the rare word scores %v, not above %v for the common one", rareHit.Score, commonHit.Score)
+	}
+}
+
+// Every word has to match, phrases match in order only and the filters narrow the results
+func TestMatching(t *testing.T) {
+	index := NewIndex()
+	names := map[primitive.ObjectID]string{}
+	add := func(name string, question model.Question) {
+		question.ID = primitive.NewObjectID()
+		index.Put(question)
+		names[question.ID] = name
+	}
+	add("sorted", model.Question{Title: "How to sort a map", Tags: []string{"go"}, Username: "Alice", Votes: 3})
+	add("reversed", model.Question{Title: "Map sort a mystery", Tags: []string{"go", "maps"}, Votes: -1})
+	add("answered", model.Question{Title: "Sort a slice", Tags: []string{"python"}, Votes: 3,
+		Answers: []model.Answer{{ID: primitive.NewObjectID(), Answer: "use sort"}}})
+	add("deleted", model.Question{Title: "Nothing", Answers: []model.Answer{{Answer: "sort a map", Deleted: true}}})
+
+	cases := map[string]string{
+		"sort map":                   "[sorted reversed]",
+		`"sort a map"`:               "[sorted]",
+		"sort tag:go":                "[sorted reversed]",
+		"sort tag:go tag:maps":       "[reversed]",
+		"sort user:alice":            "[sorted]",
+		"sort votes:>0":              "[sorted answered]",
+		"sort votes:<0":              "[reversed]",
+		"is:answered":                "[answered]",
+		"sort is:unanswered votes:3": "[sorted]",
+	}
+	for text, want := range cases {
+		hits := search(t, index, text)
+		// Scores are compared elsewhere, only the set of questions matters here
+		got := map[string]bool{}
+		for _, hit := range hits {
+			got[names[hit.ID]] = true
+		}
+		for _, name := range strings.Fields(strings.Trim(want, "[]")) {
+			if !got[name] {
+				t.Errorf("%s: %s not found in %s", text, name, hitNames(hits, names))
+			}
+			delete(got, name)
+		}
+		if len(got) > 0 {
+			t.Errorf("%s: %s, want %s", text, hitNames(hits, names), want)
+		}
+	}
+}
+
+// Questions scoring the same are ordered by votes then newest first, and a removed question is not found
+func TestTiesAndRemove(t *testing.T) {
+	index := NewIndex()
+	names := map[primitive.ObjectID]string{}
+	ids := []primitive.ObjectID{}
+	for i, votes := range []int{1, 5, 1, 0} {
+		id := primitive.NewObjectID()
+		index.Put(model.Question{ID: id, Title: "question", Tags: []string{"go"}, Votes: votes})
+		names[id] = fmt.Sprintf("q%d", i)
+		ids = append(ids, id)
+	}
+
+	hits := search(t, index, "tag:go")
+	if got := hitNames(hits, names); got != "[q1 q2 q0 q3]" {
+		t.Errorf("tag:go: %s, want [q1 q2 q0 q3]", got)
+	}
+	for i := 1; i < len(hits); i++ {
+		if !hits[i-1].Before(hits[i]) || hits[i].Before(hits[i-1]) {
+			t.Errorf("%s and %s are out of order", names[hits[i-1].ID], names[hits[i].ID])
+		}
+	}
+
+	index.Remove(ids[1])
+	if got := hitNames(search(t, index, "tag:go"), names); got != "[q2 q0 q3]" {
+		t.Errorf("tag:go after removing q1: %s, want [q2 q0 q3]", got)
+	}
+	if hits = search(t, index, "question votes:5"); len(hits) != 0 {
+		t.Errorf("removed question still found: %s", hitNames(hits, names))
+	}
+}
@@ -0,0 +1,131 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query is a parsed search. Every term, phrase and filter has to match
+type Query struct {
+	Terms   []string
+	Phrases [][]string
+
+	Tags []string
+	User string
+	// Bounds on the vote count, nil when the query does not filter on votes
+	MinVotes *int
+	MaxVotes *int
+	// is:answered, is:unanswered or is:accepted, empty when the query does not filter on answers
+	Is string
+}
+
+// ParseQuery reads a search such as `"sort a map" tag:go votes:>2 is:answered`.
+// Quoted words form a phrase, tag:, user:, votes: and is: filter the results and everything else is a term
+func ParseQuery(text string) (Query, error) {
+	var query Query
+
+	for _, part := range splitQuery(text) {
+		if strings.HasPrefix(part, `"`) {
+			phrase := Tokenize(part)
+			if len(phrase) > 0 {
+				query.Phrases = append(query.Phrases, phrase)
+			}
+			continue
+		}
+
+		name, value, isFilter := strings.Cut(part, ":")
+		switch {
+		case isFilter && name == "tag" && value != "":
+			query.Tags = append(query.Tags, strings.ToLower(value))
+		case isFilter && name == "user" && value != "":
+			query.User = value
+		case isFilter && name == "votes":
+			if err := query.parseVotes(value); err != nil {
+				return query, err
+			}
+		case isFilter && name == "is":
+			switch value {
+			case "answered", "unanswered", "accepted":
+				query.Is = value
+			default:
+				return query, fmt.Errorf("Unknown filter is:%s, use is:answered, is:unanswered or is:accepted", value)
+			}
+		default:
+			for _, word := range Tokenize(part) {
+				if !IsStopWord(word) {
+					query.Terms = append(query.Terms, word)
+				}
+			}
+		}
+	}
+
+	if query.IsEmpty() {
+		return query, errors.New("The search is empty")
+	}
+	return query, nil
+}
+
+// IsEmpty reports whether the query has nothing to match on
+func (query Query) IsEmpty() bool {
+	return len(query.Terms) == 0 && len(query.Phrases) == 0 && len(query.Tags) == 0 &&
+		query.User == "" && query.MinVotes == nil && query.MaxVotes == nil && query.Is == ""
+}
+
+// Reads votes:N, votes:>N, votes:>=N, votes:<N and votes:<=N
+func (query *Query) parseVotes(value string) error {
+	operator := strings.TrimRight(value, "-0123456789")
+	count, err := strconv.Atoi(value[len(operator):])
+	if err != nil {
+		return fmt.Errorf("Invalid filter votes:%s, use votes:>N, votes:<N or votes:N", value)
+	}
+
+	switch operator {
+	case "":
+		query.MinVotes, query.MaxVotes = &count, &count
+	case ">":
+		count++
+		query.MinVotes = &count
+	case ">=":
+		query.MinVotes = &count
+	case "<":
+		count--
+		query.MaxVotes = &count
+	case "<=":
+		query.MaxVotes = &count
+	default:
+		return fmt.Errorf("Invalid filter votes:%s, use votes:>N, votes:<N or votes:N", value)
+	}
+	return nil
+}
+
+// Splits the query on white space, keeping quoted phrases together with their quotes
+func splitQuery(text string) []string {
+	parts := []string{}
+	inPhrase := false
+	start := -1
+	for i, r := range text {
+		switch {
+		case r == '"' && inPhrase:
+			parts = append(parts, text[start:i+1])
+			inPhrase, start = false, -1
+		case r == '"':
+			if start >= 0 {
+				parts = append(parts, text[start:i])
+			}
+			inPhrase, start = true, i
+		case !inPhrase && (r == ' ' || r == '\t' || r == '\n'):
+			if start >= 0 {
+				parts = append(parts, text[start:i])
+				start = -1
+			}
+		case start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, text[start:])
+	}
+	return parts
+}
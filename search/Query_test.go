@@ -0,0 +1,56 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Stop words are dropped from the terms but kept in phrases, filter names are case sensitive and a
+// filter without a value is searched as words
+func TestParseQuery(t *testing.T) {
+	cases := map[string]string{
+		`sort a map`:                 `terms [sort map] phrases [] tags [] user "" votes <nil>..<nil> is ""`,
+		`"sort a map" go`:            `terms [go] phrases [[sort a map]] tags [] user "" votes <nil>..<nil> is ""`,
+		`tag:Go tag:maps user:Alice`: `terms [] phrases [] tags [go maps] user "Alice" votes <nil>..<nil> is ""`,
+		`is:answered C++ and C#`:     `terms [c++ c#] phrases [] tags [] user "" votes <nil>..<nil> is "answered"`,
+		`"unterminated phrase`:       `terms [] phrases [[unterminated phrase]] tags [] user "" votes <nil>..<nil> is ""`,
+		`word"quoted" `:              `terms [word] phrases [[quoted]] tags [] user "" votes <nil>..<nil> is ""`,
+		`Tag:go`:                     `terms [tag go] phrases [] tags [] user "" votes <nil>..<nil> is ""`,
+		`tag: user: loose:colon`:     `terms [tag user loose colon] phrases [] tags [] user "" votes <nil>..<nil> is ""`,
+		`votes:3`:                    `terms [] phrases [] tags [] user "" votes 3..3 is ""`,
+		`votes:>2`:                   `terms [] phrases [] tags [] user "" votes 3..<nil> is ""`,
+		`votes:>=2`:                  `terms [] phrases [] tags [] user "" votes 2..<nil> is ""`,
+		`votes:<-1`:                  `terms [] phrases [] tags [] user "" votes <nil>..-2 is ""`,
+		`votes:<=0 votes:>=-5`:       `terms [] phrases [] tags [] user "" votes -5..0 is ""`,
+	}
+	for text, want := range cases {
+		query, err := ParseQuery(text)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", text, err)
+			continue
+		}
+		if got := describeQuery(query); got != want {
+			t.Errorf("ParseQuery(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
+
+func TestInvalidQuery(t *testing.T) {
+	for _, text := range []string{"", "   ", "the and of", `""`, "is:closed", "votes:many", "votes:=>3", "votes:>"} {
+		if query, err := ParseQuery(text); err == nil {
+			t.Errorf("ParseQuery(%q) = %s, want an error", text, describeQuery(query))
+		}
+	}
+}
+
+// Writes the query out in one line, so the cases can be compared as text
+func describeQuery(query Query) string {
+	bound := func(value *int) string {
+		if value == nil {
+			return "<nil>"
+		}
+		return fmt.Sprint(*value)
+	}
+	return fmt.Sprintf("terms %v phrases %v tags %v user %q votes %s..%s is %q",
+		query.Terms, query.Phrases, query.Tags, query.User, bound(query.MinVotes), bound(query.MaxVotes), query.Is)
+}
@@ -0,0 +1,159 @@
+package search
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+
+	"example.org/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxRelated = 10
+	// Questions less related than this are left out
+	minRelatedScore = 0.1
+	// How much the tags weigh against the words in the related score
+	relatedTagWeight = 0.4
+)
+
+// Question IDs, on their own or in a URL such as https://example.org/questions/<id>
+var linkPattern = regexp.MustCompile(`\b[0-9a-fA-F]{24}\b`)
+
+// Related lists the questions close to a question and the questions linked to or from it
+type Related struct {
+	Related []Hit
+	Linked  []primitive.ObjectID
+}
+
+func findLinks(question model.Question) []primitive.ObjectID {
+	texts := []string{question.Content}
+	for _, answer := range question.Answers {
+		if !answer.Deleted {
+			texts = append(texts, answer.Answer)
+		}
+	}
+
+	links := []primitive.ObjectID{}
+	seen := map[primitive.ObjectID]bool{question.ID: true}
+	for _, text := range texts {
+		for _, match := range linkPattern.FindAllString(text, -1) {
+			id, err := primitive.ObjectIDFromHex(match)
+			if err == nil && !seen[id] {
+				seen[id] = true
+				links = append(links, id)
+			}
+		}
+	}
+	return links
+}
+
+func (doc *document) sameText(other *document) bool {
+	return reflect.DeepEqual(doc.words, other.words) && reflect.DeepEqual(doc.tags, other.tags) &&
+		reflect.DeepEqual(doc.links, other.links)
+}
+
+func (index *Index) clearRelated() {
+	index.cacheMu.Lock()
+	defer index.cacheMu.Unlock()
+	index.related = map[primitive.ObjectID]Related{}
+}
+
+// Related returns the questions sharing tags and wording with the question, the closest first, and the
+// indexed questions it links to or that link to it. Results are cached until a question's text changes
+func (index *Index) Related(id primitive.ObjectID) (Related, bool) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	doc, found := index.documents[id]
+	if !found {
+		return Related{}, false
+	}
+
+	index.cacheMu.Lock()
+	related, cached := index.related[id]
+	index.cacheMu.Unlock()
+	if cached {
+		return related, true
+	}
+
+	related = Related{Related: index.relatedTo(id, doc), Linked: index.linkedTo(id, doc)}
+
+	index.cacheMu.Lock()
+	index.related[id] = related
+	index.cacheMu.Unlock()
+	return related, true
+}
+
+// Scores the questions sharing a tag or a word with the question on tag overlap and TF-IDF similarity
+func (index *Index) relatedTo(id primitive.ObjectID, doc *document) []Hit {
+	text := index.vectorOf(doc.counts[fieldTitle], doc.counts[fieldContent])
+
+	candidates := map[primitive.ObjectID]bool{}
+	for word := range text {
+		for other := range index.postings[word] {
+			candidates[other] = true
+		}
+	}
+	for other, otherDoc := range index.documents {
+		if tagOverlap(doc.tags, otherDoc.tags) > 0 {
+			candidates[other] = true
+		}
+	}
+	delete(candidates, id)
+
+	hits := []Hit{}
+	for other := range candidates {
+		otherDoc := index.documents[other]
+		similarity := cosine(text, index.vectorOf(otherDoc.counts[fieldTitle], otherDoc.counts[fieldContent]))
+		score := relatedTagWeight*tagOverlap(doc.tags, otherDoc.tags) + (1-relatedTagWeight)*similarity
+		if score >= minRelatedScore {
+			hits = append(hits, Hit{ID: other, Score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID.Hex() > hits[j].ID.Hex()
+	})
+	if len(hits) > maxRelated {
+		hits = hits[:maxRelated]
+	}
+	return hits
+}
+
+// The indexed questions the question links to or that link to it, newest first
+func (index *Index) linkedTo(id primitive.ObjectID, doc *document) []primitive.ObjectID {
+	seen := map[primitive.ObjectID]bool{}
+	for _, link := range doc.links {
+		if index.documents[link] != nil {
+			seen[link] = true
+		}
+	}
+	for from := range index.linkedFrom[id] {
+		seen[from] = true
+	}
+
+	linked := []primitive.ObjectID{}
+	for link := range seen {
+		linked = append(linked, link)
+	}
+	sort.Slice(linked, func(i, j int) bool { return linked[i].Hex() > linked[j].Hex() })
+	return linked
+}
+
+// The Jaccard index of the tags, the shared tags over all the tags of both questions
+func tagOverlap(a []string, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for _, tag := range a {
+		if containsString(b, tag) {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
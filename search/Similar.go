@@ -0,0 +1,103 @@
+package search
+
+import (
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A TF-IDF weighted word vector
+type vector map[string]float64
+
+// The sums of the cosine run over maps, in no set order, so the same similarity can come out a
+// rounding error apart. Questions that far below the minimum are still kept, so an identical
+// question always reaches a threshold of 1
+const similarityTolerance = 1e-9
+
+// Reaches reports whether the similarity reaches the threshold, allowing for that rounding error
+func Reaches(similarity float64, threshold float64) bool {
+	return similarity >= threshold-similarityTolerance
+}
+
+func (index *Index) idf(word string) float64 {
+	return math.Log(1 + float64(len(index.documents)+1)/float64(len(index.postings[word])+1))
+}
+
+func (index *Index) vectorOf(counts ...map[string]int) vector {
+	v := vector{}
+	for _, c := range counts {
+		for word, count := range c {
+			v[word] += float64(count) * index.idf(word)
+		}
+	}
+	return v
+}
+
+func countWords(text string) map[string]int {
+	counts := map[string]int{}
+	for _, word := range Tokenize(text) {
+		if !IsStopWord(word) {
+			counts[word]++
+		}
+	}
+	return counts
+}
+
+func cosine(a vector, b vector) float64 {
+	dot, normA, normB := 0.0, 0.0, 0.0
+	for word, weight := range a {
+		dot += weight * b[word]
+		normA += weight * weight
+	}
+	for _, weight := range b {
+		normB += weight * weight
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// Similar returns the questions whose wording is closest to the title and content, at most limit of them
+// and only those at least minimum similar. The similarity, between 0 and 1, is the cosine of the TF-IDF
+// vectors of the titles or of the titles and contents together, whichever is higher, so a question asked
+// again in other words is still found from its title
+func (index *Index) Similar(title string, content string, minimum float64, limit int) []Hit {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	titleCounts, contentCounts := countWords(title), countWords(content)
+	titleVector := index.vectorOf(titleCounts)
+	fullVector := index.vectorOf(titleCounts, contentCounts)
+
+	candidates := map[primitive.ObjectID]bool{}
+	for word := range fullVector {
+		for id := range index.postings[word] {
+			candidates[id] = true
+		}
+	}
+
+	hits := []Hit{}
+	for id := range candidates {
+		doc := index.documents[id]
+		similarity := cosine(titleVector, index.vectorOf(doc.counts[fieldTitle]))
+		if len(contentCounts) > 0 {
+			similarity = math.Max(similarity, cosine(fullVector, index.vectorOf(doc.counts[fieldTitle], doc.counts[fieldContent])))
+		}
+		if Reaches(similarity, minimum) {
+			hits = append(hits, Hit{ID: id, Score: similarity})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID.Hex() > hits[j].ID.Hex()
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
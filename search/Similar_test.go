@@ -0,0 +1,91 @@
+package search
+
+import (
+	"testing"
+
+	"example.org/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The similarity of the title and content to the one indexed question, 0 when it is not returned
+func similarity(index *Index, title string, content string) float64 {
+	hits := index.Similar(title, content, 0, 10)
+	if len(hits) == 0 {
+		return 0
+	}
+	return hits[0].Score
+}
+
+func TestSimilarity(t *testing.T) {
+	index := NewIndex()
+	pad(index)
+	id := put(index, "How do I reverse a linked list in Go", "I have a singly linked list and want it backwards without copying")
+
+	near := func(a float64, b float64) bool { return a-b < 1e-9 && b-a < 1e-9 }
+	if s := similarity(index, "How do I reverse a linked list in Go", "I have a singly linked list and want it backwards without copying"); !near(s, 1) {
+		t.Errorf("the same question: %v, want 1", s)
+	}
+	// Case, word order and stop words do not count
+	if s := similarity(index, "go: REVERSE linked list", ""); !near(s, 1) {
+		t.Errorf("the same title reworded: %v, want 1", s)
+	}
+	// A title asked again is found even when the content says something else
+	if s := similarity(index, "Reverse a linked list in Go", "my teacher wants recursion, the iterative answers are not it"); !near(s, 1) {
+		t.Errorf("the same title with another content: %v, want 1", s)
+	}
+
+	partial := similarity(index, "Reverse a slice in Go", "")
+	if partial <= 0 || partial >= 0.8 {
+		t.Errorf("a title sharing two of four words: %v, want between 0 and the default threshold", partial)
+	}
+	if hits := index.Similar("Bake a cake", "flour and eggs", 0, 10); len(hits) != 0 {
+		t.Errorf("a question sharing no word was found: %v", hits)
+	}
+	if hits := index.Similar("Reverse a linked list in Go", "", 0, 10); len(hits) != 1 || hits[0].ID != id {
+		t.Errorf("Similar = %v, want only the question", hits)
+	}
+}
+
+// Questions below the minimum are left out, the rest come most similar first up to the limit
+func TestSimilarThresholds(t *testing.T) {
+	index := NewIndex()
+	pad(index)
+	names := map[primitive.ObjectID]string{}
+	for name, title := range map[string]string{
+		"same":    "parse json into a struct",
+		"close":   "parse json into a map",
+		"distant": "json schema validation",
+		"other":   "parse yaml config",
+	} {
+		question := model.Question{ID: primitive.NewObjectID(), Title: title}
+		index.Put(question)
+		names[question.ID] = name
+	}
+
+	all := index.Similar("parse json into a struct", "", 0, 10)
+	if got := hitNames(all, names); got != "[same close distant other]" && got != "[same close other distant]" {
+		t.Fatalf("Similar = %s, want same and close first", got)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Score > all[i-1].Score {
+			t.Errorf("%s scores above %s", names[all[i].ID], names[all[i-1].ID])
+		}
+	}
+
+	// The minimum is inclusive, a question exactly at it is kept
+	for _, hit := range all {
+		kept := index.Similar("parse json into a struct", "", hit.Score, 10)
+		if len(kept) == 0 || kept[len(kept)-1].Score > hit.Score+similarityTolerance {
+			t.Errorf("minimum %v leaves out %s: %s", hit.Score, names[hit.ID], hitNames(kept, names))
+		}
+	}
+	if kept := index.Similar("parse json into a struct", "", 1, 10); hitNames(kept, names) != "[same]" {
+		t.Errorf("minimum 1: %s, want [same]", hitNames(kept, names))
+	}
+	if kept := index.Similar("parse json into a struct", "", 0.8, 10); hitNames(kept, names) != "[same]" {
+		t.Errorf("at the default threshold: %s, want [same]", hitNames(kept, names))
+	}
+	if kept := index.Similar("parse json into a struct", "", 0, 2); hitNames(kept, names) != "[same close]" {
+		t.Errorf("limit 2: %s, want [same close]", hitNames(kept, names))
+	}
+}
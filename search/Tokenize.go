@@ -0,0 +1,34 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Words too common to tell questions apart. They are not indexed, but phrases still match across them
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"can": true, "do": true, "does": true, "for": true, "from": true, "how": true, "i": true, "if": true,
+	"in": true, "is": true, "it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "what": true, "when": true, "with": true,
+}
+
+// Tokenize splits text into lower case words. + and # are kept inside words so c++ and c# survive
+func Tokenize(text string) []string {
+	tokens := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+' && r != '#'
+	})
+
+	words := tokens[:0]
+	for _, token := range tokens {
+		if token = strings.TrimLeft(token, "+#"); token != "" {
+			words = append(words, token)
+		}
+	}
+	return words
+}
+
+// IsStopWord reports whether the word is left out of the index
+func IsStopWord(word string) bool {
+	return stopWords[word]
+}
@@ -0,0 +1,973 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"example.org/model"
+	"example.org/search"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewMemoryStore returns a Store that keeps everything in process memory.
+// Nothing is persisted, which makes it suitable for tests and local runs without MongoDB.
+func NewMemoryStore() *Store {
+	index := search.NewIndex()
+	return &Store{
+		Users:      &memoryUserStore{},
+		Questions:  newIndexedQuestionStore(&memoryQuestionStore{}, index),
+		Votes:      &memoryVoteStore{votes: map[model.VoteKey]model.Vote{}},
+		Tags:       &memoryTagStore{tags: map[string]model.Tag{}, synonyms: map[string]model.TagSynonym{}},
+		Revisions:  &memoryRevisionStore{},
+		Comments:   &memoryCommentStore{},
+		Reputation: &memoryReputationStore{},
+		Tokens: &memoryTokenStore{
+			refreshTokens: map[string]model.RefreshToken{},
+			revokedTokens: map[string]time.Time{},
+		},
+		Search:       index,
+		Transactions: &memoryTransactor{},
+	}
+}
+
+// The memory store cannot roll writes back, it runs one group of writes at a time instead
+type memoryTransactor struct {
+	mu sync.Mutex
+}
+
+func (t *memoryTransactor) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return fn(ctx)
+}
+
+type memoryUserStore struct {
+	mu    sync.RWMutex
+	users []model.UserReturnModel
+}
+
+func (s *memoryUserStore) findOne(match func(user *model.UserReturnModel) bool) (model.UserReturnModel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.users {
+		if match(&s.users[i]) {
+			return s.users[i], nil
+		}
+	}
+	return model.UserReturnModel{}, ErrNotFound
+}
+
+func (s *memoryUserStore) FindByEmail(ctx context.Context, email string) (model.UserReturnModel, error) {
+	return s.findOne(func(user *model.UserReturnModel) bool {
+		return user.Email == email
+	})
+}
+
+func (s *memoryUserStore) FindByUsername(ctx context.Context, username string) (model.UserReturnModel, error) {
+	return s.findOne(func(user *model.UserReturnModel) bool {
+		return user.Username == username
+	})
+}
+
+func (s *memoryUserStore) FindByUsernameAndEmail(ctx context.Context, username string, email string) (model.UserReturnModel, error) {
+	return s.findOne(func(user *model.UserReturnModel) bool {
+		return user.Username == username && user.Email == email
+	})
+}
+
+func (s *memoryUserStore) Insert(ctx context.Context, user *model.UserModel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Checked under the lock so concurrent sign-ups cannot both take the name
+	for _, existing := range s.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	s.users = append(s.users, model.UserReturnModel{
+		ID:       primitive.NewObjectID(),
+		Username: user.Username,
+		Password: user.Password,
+		Email:    user.Email,
+		Country:  user.Country,
+		Phone:    user.Phone,
+		City:     user.City,
+	})
+	return nil
+}
+
+func (s *memoryUserStore) update(username string, change func(user *model.UserReturnModel)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.users {
+		if s.users[i].Username == username {
+			change(&s.users[i])
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryUserStore) AddReputation(ctx context.Context, username string, delta int) error {
+	return s.update(username, func(user *model.UserReturnModel) {
+		user.Reputation += delta
+	})
+}
+
+func (s *memoryUserStore) SetReputation(ctx context.Context, username string, reputation int) error {
+	return s.update(username, func(user *model.UserReturnModel) {
+		user.Reputation = reputation
+	})
+}
+
+func (s *memoryUserStore) Reputations(ctx context.Context, usernames []string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reputations := map[string]int{}
+	for _, user := range s.users {
+		if containsTag(usernames, user.Username) {
+			reputations[user.Username] = user.Reputation
+		}
+	}
+	return reputations, nil
+}
+
+type memoryQuestionStore struct {
+	mu        sync.RWMutex
+	questions []model.Question
+}
+
+// Questions are copied on the way in and out so callers never share the answers slice with the store
+func copyQuestion(question model.Question) model.Question {
+	question.Answers = append([]model.Answer{}, question.Answers...)
+	question.CloseVotes = append([]model.CloseVote{}, question.CloseVotes...)
+	return question
+}
+
+func (s *memoryQuestionStore) findIndex(match func(question *model.Question) bool) int {
+	for i := range s.questions {
+		if match(&s.questions[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func findAnswerIndex(question *model.Question, answerID primitive.ObjectID) int {
+	for i := range question.Answers {
+		if question.Answers[i].ID == answerID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *memoryQuestionStore) findOne(match func(question *model.Question) bool) (model.Question, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.findIndex(match)
+	if i < 0 {
+		return model.Question{}, ErrNotFound
+	}
+	return copyQuestion(s.questions[i]), nil
+}
+
+func (s *memoryQuestionStore) FindByID(ctx context.Context, id primitive.ObjectID) (model.Question, error) {
+	return s.findOne(func(question *model.Question) bool {
+		return question.ID == id
+	})
+}
+
+func (s *memoryQuestionStore) FindByTitle(ctx context.Context, title string) (model.Question, error) {
+	return s.findOne(func(question *model.Question) bool {
+		return question.Title == title
+	})
+}
+
+func (s *memoryQuestionStore) FindByUsernameAndTitle(ctx context.Context, username string, title string) (model.Question, error) {
+	return s.findOne(func(question *model.Question) bool {
+		return question.Username == username && question.Title == title
+	})
+}
+
+func (s *memoryQuestionStore) Insert(ctx context.Context, question *model.Question) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if question.ID.IsZero() {
+		question.ID = primitive.NewObjectID()
+	}
+	question.Hot = hotScore(question.Votes, question.AnswerCount, question.ID.Timestamp())
+	s.questions = append(s.questions, copyQuestion(*question))
+	return nil
+}
+
+func (s *memoryQuestionStore) AddAnswer(ctx context.Context, id primitive.ObjectID, answer model.Answer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findIndex(func(question *model.Question) bool {
+		return question.ID == id
+	})
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.questions[i].Answers = append(s.questions[i].Answers, answer)
+	s.questions[i].AnswerCount++
+	s.questions[i].LastAnswerAt = answer.DatePosted
+	s.questions[i].LastActivityAt = answer.DatePosted
+	s.questions[i].Hot = hotScore(s.questions[i].Votes, s.questions[i].AnswerCount, s.questions[i].ID.Timestamp())
+	return nil
+}
+
+func (s *memoryQuestionStore) IncrementVotes(ctx context.Context, id primitive.ObjectID, upvotes int, downvotes int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findIndex(func(question *model.Question) bool {
+		return question.ID == id
+	})
+	if i < 0 {
+		return ErrNotFound
+	}
+	question := &s.questions[i]
+	question.Upvotes += upvotes
+	question.Downvotes += downvotes
+	question.Votes += upvotes - downvotes
+	question.Controversy = controversy(question.Upvotes, question.Downvotes)
+	question.LastActivityAt = time.Now()
+	question.Hot = hotScore(question.Votes, question.AnswerCount, question.ID.Timestamp())
+	return nil
+}
+
+func (s *memoryQuestionStore) IncrementAnswerVotes(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, delta int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findIndex(func(question *model.Question) bool {
+		return question.ID == id
+	})
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	question := &s.questions[i]
+	j := findAnswerIndex(question, answerID)
+	if j < 0 {
+		return ErrNotFound
+	}
+	question.Answers[j].Votes += delta
+	if question.SelectedAnswer.ID == answerID {
+		question.SelectedAnswer.Votes += delta
+	}
+	question.LastActivityAt = time.Now()
+	return nil
+}
+
+func (s *memoryQuestionStore) SetSelectedAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID) (model.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findIndex(func(question *model.Question) bool {
+		return question.ID == id
+	})
+	if i < 0 {
+		return model.Question{}, ErrNotFound
+	}
+
+	question := &s.questions[i]
+	selected := model.Answer{}
+	if !answerID.IsZero() {
+		j := findAnswerIndex(question, answerID)
+		if j < 0 {
+			return model.Question{}, ErrNotFound
+		}
+		selected = question.Answers[j]
+		selected.ISSelected = true
+	}
+
+	for j := range question.Answers {
+		question.Answers[j].ISSelected = question.Answers[j].ID == answerID
+	}
+	question.SelectedAnswer = selected
+	question.LastActivityAt = time.Now()
+	return copyQuestion(*question), nil
+}
+
+func (s *memoryQuestionStore) List(ctx context.Context, query QuestionQuery) ([]model.Question, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	questionSort := questionSorts[query.Sort]
+	questions := []model.Question{}
+	for _, question := range s.questions {
+		if questionSort.match != nil && !questionSort.match(question) {
+			continue
+		}
+		if !query.inRange(question) || !query.hasTags(question) || !query.hasState(question) || !query.hasCloseVotes(question) {
+			continue
+		}
+		if query.After == nil || cursorBefore(*query.After, CursorOf(query, question)) {
+			questions = append(questions, question)
+		}
+	}
+	sort.Slice(questions, func(i, j int) bool {
+		return cursorBefore(CursorOf(query, questions[i]), CursorOf(query, questions[j]))
+	})
+
+	hasMore := len(questions) > query.Limit
+	if hasMore {
+		questions = questions[:query.Limit]
+	}
+	for i := range questions {
+		questions[i] = copyQuestion(questions[i])
+	}
+	return questions, hasMore, nil
+}
+
+func (s *memoryQuestionStore) PopularTags(ctx context.Context, limit int) ([]model.TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]int{}
+	for _, question := range s.questions {
+		if question.CurrentState() == model.QuestionDeleted {
+			continue
+		}
+		for _, tag := range question.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]model.TagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, model.TagCount{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
+func (s *memoryQuestionStore) TagStats(ctx context.Context, tag string) (model.TagStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats model.TagStats
+	weekAgo := time.Now().AddDate(0, 0, -7)
+	query := QuestionQuery{Tags: []string{tag}}
+	for _, question := range s.questions {
+		if !query.hasTags(question) || !query.hasState(question) {
+			continue
+		}
+		stats.Questions++
+		if question.AnswerCount == 0 {
+			stats.Unanswered++
+		}
+		stats.Answers += question.AnswerCount
+		stats.Votes += question.Votes
+		if question.CreatedAt.After(weekAgo) {
+			stats.QuestionsThisWeek++
+		}
+		if question.CreatedAt.After(stats.LastUsedAt) {
+			stats.LastUsedAt = question.CreatedAt
+		}
+	}
+	return stats, nil
+}
+
+func (s *memoryQuestionStore) RenameTag(ctx context.Context, from string, to string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	renamed := 0
+	for i := range s.questions {
+		question := &s.questions[i]
+		if !containsTag(question.Tags, from) {
+			continue
+		}
+		tags := []string{}
+		for _, tag := range question.Tags {
+			if tag == from {
+				tag = to
+			}
+			if !containsTag(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		question.Tags = tags
+		renamed++
+	}
+	return renamed, nil
+}
+
+func (s *memoryQuestionStore) Edit(ctx context.Context, id primitive.ObjectID, title string, content string, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findIndex(func(question *model.Question) bool {
+		return question.ID == id
+	})
+	if i < 0 {
+		return ErrNotFound
+	}
+	question := &s.questions[i]
+	question.Title = title
+	question.Content = content
+	question.Tags = append([]string{}, tags...)
+	question.UpdatedAt = time.Now()
+	question.LastActivityAt = question.UpdatedAt
+	return nil
+}
+
+func (s *memoryQuestionStore) EditAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findIndex(func(question *model.Question) bool {
+		return question.ID == id
+	})
+	if i < 0 {
+		return ErrNotFound
+	}
+	question := &s.questions[i]
+	j := findAnswerIndex(question, answerID)
+	if j < 0 || question.Answers[j].Deleted {
+		return ErrNotFound
+	}
+
+	answer := &question.Answers[j]
+	answer.Answer = text
+	answer.UpdatedAt = time.Now()
+	if question.SelectedAnswer.ID == answerID {
+		question.SelectedAnswer.Answer = answer.Answer
+		question.SelectedAnswer.UpdatedAt = answer.UpdatedAt
+	}
+	question.LastActivityAt = answer.UpdatedAt
+	return nil
+}
+
+func (s *memoryQuestionStore) DeleteAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, deletedBy string) (model.Question, error) {
+	return s.setAnswerDeleted(id, answerID, true, deletedBy)
+}
+
+func (s *memoryQuestionStore) UndeleteAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID) (model.Question, error) {
+	return s.setAnswerDeleted(id, answerID, false, "")
+}
+
+func (s *memoryQuestionStore) setAnswerDeleted(id primitive.ObjectID, answerID primitive.ObjectID, deleted bool, deletedBy string) (model.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findIndex(func(question *model.Question) bool {
+		return question.ID == id
+	})
+	if i < 0 {
+		return model.Question{}, ErrNotFound
+	}
+	question := &s.questions[i]
+	j := findAnswerIndex(question, answerID)
+	if j < 0 || question.Answers[j].Deleted == deleted {
+		return model.Question{}, ErrNotFound
+	}
+
+	answer := &question.Answers[j]
+	answer.Deleted = deleted
+	answer.DeletedBy = deletedBy
+	answer.DeletedAt = time.Time{}
+	if deleted {
+		answer.DeletedAt = time.Now()
+		answer.ISSelected = false
+		question.AnswerCount--
+		if question.SelectedAnswer.ID == answerID {
+			question.SelectedAnswer = model.Answer{}
+		}
+	} else {
+		question.AnswerCount++
+	}
+	question.LastActivityAt = time.Now()
+	question.Hot = hotScore(question.Votes, question.AnswerCount, question.ID.Timestamp())
+	return copyQuestion(*question), nil
+}
+
+func (s *memoryQuestionStore) SetStatus(ctx context.Context, id primitive.ObjectID, status model.QuestionStatus) (model.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findIndex(func(question *model.Question) bool {
+		return question.ID == id
+	})
+	if i < 0 {
+		return model.Question{}, ErrNotFound
+	}
+	s.questions[i].Status = status
+	s.questions[i].CloseVotes = nil
+	return copyQuestion(s.questions[i]), nil
+}
+
+func (s *memoryQuestionStore) AddCloseVote(ctx context.Context, id primitive.ObjectID, vote model.CloseVote) (model.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findIndex(func(question *model.Question) bool {
+		return question.ID == id
+	})
+	if i < 0 || s.questions[i].CurrentState() != model.QuestionOpen {
+		return model.Question{}, ErrNotFound
+	}
+	for _, closeVote := range s.questions[i].CloseVotes {
+		if closeVote.Username == vote.Username {
+			return model.Question{}, ErrDuplicate
+		}
+	}
+	s.questions[i].CloseVotes = append(s.questions[i].CloseVotes, vote)
+	return copyQuestion(s.questions[i]), nil
+}
+
+type memoryRevisionStore struct {
+	mu        sync.RWMutex
+	revisions []model.Revision
+}
+
+func copyRevision(revision model.Revision) model.Revision {
+	revision.Changed = append([]string{}, revision.Changed...)
+	revision.Tags = append([]string{}, revision.Tags...)
+	return revision
+}
+
+func (s *memoryRevisionStore) Insert(ctx context.Context, revision *model.Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.revisions {
+		if stored.QuestionID == revision.QuestionID && stored.AnswerID == revision.AnswerID && stored.Number == revision.Number {
+			return ErrDuplicate
+		}
+	}
+	if revision.ID.IsZero() {
+		revision.ID = primitive.NewObjectID()
+	}
+	s.revisions = append(s.revisions, copyRevision(*revision))
+	return nil
+}
+
+func (s *memoryRevisionStore) List(ctx context.Context, questionID primitive.ObjectID, answerID primitive.ObjectID) ([]model.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := []model.Revision{}
+	for _, revision := range s.revisions {
+		if revision.QuestionID == questionID && revision.AnswerID == answerID {
+			revisions = append(revisions, copyRevision(revision))
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Number < revisions[j].Number })
+	return revisions, nil
+}
+
+func (s *memoryRevisionStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, revision := range s.revisions {
+		if revision.ID == id {
+			s.revisions = append(s.revisions[:i], s.revisions[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+type memoryReputationStore struct {
+	mu      sync.RWMutex
+	entries []model.ReputationEntry
+}
+
+func (s *memoryReputationStore) Insert(ctx context.Context, entry *model.ReputationEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	s.entries = append(s.entries, *entry)
+	return nil
+}
+
+// Entries are appended as they are made, so the last match is the latest
+func (s *memoryReputationStore) Last(ctx context.Context, username string, reason string, actor string, questionID primitive.ObjectID, answerID primitive.ObjectID) (model.ReputationEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		entry := s.entries[i]
+		if entry.Username == username && entry.Reason == reason && entry.Actor == actor && entry.QuestionID == questionID && entry.AnswerID == answerID {
+			return entry, nil
+		}
+	}
+	return model.ReputationEntry{}, ErrNotFound
+}
+
+func (s *memoryReputationStore) Sum(ctx context.Context, username string, reasons []string, since time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sum := 0
+	for _, entry := range s.entries {
+		if entry.Username == username && containsTag(reasons, entry.Reason) && !entry.CreatedAt.Before(since) {
+			sum += entry.Points
+		}
+	}
+	return sum, nil
+}
+
+func (s *memoryReputationStore) List(ctx context.Context, username string) ([]model.ReputationEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []model.ReputationEntry{}
+	for _, entry := range s.entries {
+		if entry.Username == username {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+type memoryCommentStore struct {
+	mu       sync.RWMutex
+	comments []model.Comment
+}
+
+func copyComment(comment model.Comment) model.Comment {
+	comment.Mentions = append([]string{}, comment.Mentions...)
+	comment.Upvoters = append([]string{}, comment.Upvoters...)
+	return comment
+}
+
+func (s *memoryCommentStore) Insert(ctx context.Context, comment *model.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if comment.ID.IsZero() {
+		comment.ID = primitive.NewObjectID()
+	}
+	s.comments = append(s.comments, copyComment(*comment))
+	return nil
+}
+
+func (s *memoryCommentStore) FindByID(ctx context.Context, id primitive.ObjectID) (model.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, comment := range s.comments {
+		if comment.ID == id {
+			return copyComment(comment), nil
+		}
+	}
+	return model.Comment{}, ErrNotFound
+}
+
+// Comments are appended as they are posted, so the slice is already oldest first
+func (s *memoryCommentStore) filter(match func(comment *model.Comment) bool) []model.Comment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comments := []model.Comment{}
+	for i := range s.comments {
+		if match(&s.comments[i]) {
+			comments = append(comments, copyComment(s.comments[i]))
+		}
+	}
+	return comments
+}
+
+func (s *memoryCommentStore) List(ctx context.Context, questionID primitive.ObjectID, answerID primitive.ObjectID) ([]model.Comment, error) {
+	return s.filter(func(comment *model.Comment) bool {
+		return comment.QuestionID == questionID && comment.AnswerID == answerID
+	}), nil
+}
+
+func (s *memoryCommentStore) ListByQuestion(ctx context.Context, questionID primitive.ObjectID) ([]model.Comment, error) {
+	return s.filter(func(comment *model.Comment) bool {
+		return comment.QuestionID == questionID
+	}), nil
+}
+
+func (s *memoryCommentStore) update(id primitive.ObjectID, change func(comment *model.Comment)) (model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.comments {
+		if s.comments[i].ID == id {
+			change(&s.comments[i])
+			return copyComment(s.comments[i]), nil
+		}
+	}
+	return model.Comment{}, ErrNotFound
+}
+
+func (s *memoryCommentStore) Edit(ctx context.Context, id primitive.ObjectID, text string, mentions []string) (model.Comment, error) {
+	return s.update(id, func(comment *model.Comment) {
+		comment.Text = text
+		comment.Mentions = append([]string{}, mentions...)
+		comment.UpdatedAt = time.Now()
+	})
+}
+
+func (s *memoryCommentStore) Upvote(ctx context.Context, id primitive.ObjectID, username string, upvote bool) (model.Comment, error) {
+	return s.update(id, func(comment *model.Comment) {
+		for i, upvoter := range comment.Upvoters {
+			if upvoter == username {
+				if !upvote {
+					comment.Upvoters = append(comment.Upvoters[:i:i], comment.Upvoters[i+1:]...)
+					comment.Upvotes--
+				}
+				return
+			}
+		}
+		if upvote {
+			comment.Upvoters = append(comment.Upvoters, username)
+			comment.Upvotes++
+		}
+	})
+}
+
+type memoryVoteStore struct {
+	mu    sync.Mutex
+	votes map[model.VoteKey]model.Vote
+}
+
+func (s *memoryVoteStore) Swap(ctx context.Context, vote model.Vote) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := model.VoteNone
+	if current, found := s.votes[vote.Key]; found {
+		previous = current.Vote
+	}
+	if vote.Vote == model.VoteNone {
+		delete(s.votes, vote.Key)
+	} else {
+		s.votes[vote.Key] = vote
+	}
+	return previous, nil
+}
+
+func (s *memoryVoteStore) List(ctx context.Context, questionID primitive.ObjectID, answerID primitive.ObjectID) ([]model.Vote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	votes := []model.Vote{}
+	for key, vote := range s.votes {
+		if key.QuestionID == questionID && key.AnswerID == answerID {
+			votes = append(votes, vote)
+		}
+	}
+	return votes, nil
+}
+
+type memoryTagStore struct {
+	mu       sync.RWMutex
+	tags     map[string]model.Tag
+	synonyms map[string]model.TagSynonym
+}
+
+func (s *memoryTagStore) FindByName(ctx context.Context, name string) (model.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tag, found := s.tags[name]
+	if !found {
+		return model.Tag{}, ErrNotFound
+	}
+	return tag, nil
+}
+
+func (s *memoryTagStore) EnsureTags(ctx context.Context, names []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range names {
+		if _, found := s.tags[name]; !found {
+			s.tags[name] = model.Tag{Name: name, CreatedAt: time.Now()}
+		}
+	}
+	return nil
+}
+
+func (s *memoryTagStore) UpdateWiki(ctx context.Context, name string, excerpt string, wiki string, editor string) (model.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag, found := s.tags[name]
+	if !found {
+		return model.Tag{}, ErrNotFound
+	}
+	tag.Excerpt = excerpt
+	tag.Wiki = wiki
+	tag.EditedBy = editor
+	tag.EditedAt = time.Now()
+	s.tags[name] = tag
+	return tag, nil
+}
+
+func (s *memoryTagStore) FindSynonym(ctx context.Context, source string) (model.TagSynonym, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	synonym, found := s.synonyms[source]
+	if !found {
+		return model.TagSynonym{}, ErrNotFound
+	}
+	return synonym, nil
+}
+
+func (s *memoryTagStore) findSynonyms(match func(synonym model.TagSynonym) bool) []model.TagSynonym {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	synonyms := []model.TagSynonym{}
+	for _, synonym := range s.synonyms {
+		if match(synonym) {
+			synonyms = append(synonyms, synonym)
+		}
+	}
+	sort.Slice(synonyms, func(i, j int) bool {
+		return synonyms[i].Source < synonyms[j].Source
+	})
+	return synonyms
+}
+
+func (s *memoryTagStore) FindSynonymsByTarget(ctx context.Context, target string) ([]model.TagSynonym, error) {
+	return s.findSynonyms(func(synonym model.TagSynonym) bool {
+		return synonym.Target == target
+	}), nil
+}
+
+func (s *memoryTagStore) FindSynonymsByStatus(ctx context.Context, status string) ([]model.TagSynonym, error) {
+	return s.findSynonyms(func(synonym model.TagSynonym) bool {
+		return synonym.Status == status
+	}), nil
+}
+
+func (s *memoryTagStore) InsertSynonym(ctx context.Context, synonym *model.TagSynonym) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.synonyms[synonym.Source]; found {
+		return ErrDuplicate
+	}
+	s.synonyms[synonym.Source] = *synonym
+	return nil
+}
+
+func (s *memoryTagStore) ReviewSynonym(ctx context.Context, source string, status string, reviewer string) (model.TagSynonym, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	synonym, found := s.synonyms[source]
+	if !found || synonym.Status != model.SynonymPending {
+		return model.TagSynonym{}, ErrNotFound
+	}
+	synonym.Status = status
+	synonym.ReviewedBy = reviewer
+	synonym.ReviewedAt = time.Now()
+	s.synonyms[source] = synonym
+	return synonym, nil
+}
+
+func (s *memoryTagStore) ReplaceSynonym(ctx context.Context, synonym *model.TagSynonym) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.synonyms[synonym.Source] = *synonym
+	return nil
+}
+
+type memoryTokenStore struct {
+	mu            sync.Mutex
+	refreshTokens map[string]model.RefreshToken
+	revokedTokens map[string]time.Time
+}
+
+// Drops the entries that expired on their own, like the TTL indexes do in Mongo
+func (s *memoryTokenStore) prune() {
+	now := time.Now()
+	for id, expiry := range s.revokedTokens {
+		if expiry.Before(now) {
+			delete(s.revokedTokens, id)
+		}
+	}
+	for id, token := range s.refreshTokens {
+		if token.ExpiresAt.Before(now) {
+			delete(s.refreshTokens, id)
+		}
+	}
+}
+
+func (s *memoryTokenStore) InsertRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	s.refreshTokens[token.ID] = *token
+	return nil
+}
+
+func (s *memoryTokenStore) ConsumeRefreshToken(ctx context.Context, id string) (model.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, found := s.refreshTokens[id]
+	if !found {
+		return model.RefreshToken{}, ErrNotFound
+	}
+	used := token
+	used.Used = true
+	s.refreshTokens[id] = used
+	return token, nil
+}
+
+func (s *memoryTokenStore) RevokeRefreshFamily(ctx context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.refreshTokens {
+		if token.Family == family {
+			token.Used = true
+			s.refreshTokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (s *memoryTokenStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	s.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (s *memoryTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, found := s.revokedTokens[jti]
+	return found, nil
+}
@@ -0,0 +1,1085 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"example.org/model"
+	"example.org/search"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoStore returns a Store backed by the users, questions, uservotes, tag, revision, comment, reputation and token
+// collections of the database. Without transactions, which a standalone server does not support, grouped writes
+// run one after the other
+func NewMongoStore(QAEngineDatabase *mongo.Database, transactions bool) *Store {
+	index := search.NewIndex()
+	return &Store{
+		Users:     &mongoUserStore{collection: QAEngineDatabase.Collection("users")},
+		Questions: newIndexedQuestionStore(&mongoQuestionStore{collection: QAEngineDatabase.Collection("questions")}, index),
+		Votes:     &mongoVoteStore{collection: QAEngineDatabase.Collection("uservotes")},
+		Tags: &mongoTagStore{
+			collection: QAEngineDatabase.Collection("tags"),
+			synonyms:   QAEngineDatabase.Collection("tagsynonyms"),
+		},
+		Revisions:  &mongoRevisionStore{collection: QAEngineDatabase.Collection("revisions")},
+		Comments:   &mongoCommentStore{collection: QAEngineDatabase.Collection("comments")},
+		Reputation: &mongoReputationStore{collection: QAEngineDatabase.Collection("reputation")},
+		Tokens: &mongoTokenStore{
+			refreshTokens: QAEngineDatabase.Collection("refreshtokens"),
+			revokedTokens: QAEngineDatabase.Collection("revokedtokens"),
+		},
+		Search:       index,
+		Transactions: &mongoTransactor{client: QAEngineDatabase.Client(), enabled: transactions},
+	}
+}
+
+// MongoSupportsTransactions reports whether the server is a replica set member or a mongos, the deployments
+// that support transactions
+func MongoSupportsTransactions(ctx context.Context, client *mongo.Client) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+type mongoTransactor struct {
+	client  *mongo.Client
+	enabled bool
+}
+
+func (t *mongoTransactor) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !t.enabled {
+		return fn(ctx)
+	}
+
+	session, err := t.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionContext)
+	})
+	return err
+}
+
+// EnsureMongoIndexes creates the indexes the Mongo store relies on. It is safe to call on every start
+func EnsureMongoIndexes(ctx context.Context, QAEngineDatabase *mongo.Database) error {
+	// Expired tokens are removed by Mongo itself
+	for _, name := range []string{"refreshtokens", "revokedtokens"} {
+		_, err := QAEngineDatabase.Collection(name).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.M{"expiresat": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := QAEngineDatabase.Collection("refreshtokens").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"family": 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Registration checks before inserting, the unique indexes settle concurrent sign-ups
+	for _, field := range []string{"username", "email"} {
+		_, err = QAEngineDatabase.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.M{field: 1},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Multikey index for the tag filters and counts
+	_, err = QAEngineDatabase.Collection("questions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"tags": 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, field := range []string{"target", "status"} {
+		_, err = QAEngineDatabase.Collection("tagsynonyms").Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.M{field: 1},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Numbering the revisions of a post twice fails, which keeps concurrent edits from both landing
+	_, err = QAEngineDatabase.Collection("revisions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "questionid", Value: 1}, {Key: "answerid", Value: 1}, {Key: "number", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The comments are read per question, in the order they were posted
+	_, err = QAEngineDatabase.Collection("comments").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "questionid", Value: 1}, {Key: "createdat", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The votes on an answer are read when it is deleted or undeleted
+	_, err = QAEngineDatabase.Collection("uservotes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "_id.questionid", Value: 1}, {Key: "_id.answerid", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The ledger is read per user, the latest entries first or by day
+	_, err = QAEngineDatabase.Collection("reputation").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "username", Value: 1}, {Key: "createdat", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One index per sort order of the listings
+	for _, sort := range questionSorts {
+		if sort.field == "" {
+			continue
+		}
+		_, err = QAEngineDatabase.Collection("questions").Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: sort.field, Value: 1}, {Key: "_id", Value: 1}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateQuestions fills in the fields the listings sort on, the timestamps, the state and the answer ids
+// of questions stored before they existed. The split of the older vote counts is unknown, so they are taken
+// as all upvotes or all downvotes. The hot score follows the counts it is computed from
+func MigrateQuestions(ctx context.Context, QAEngineDatabase *mongo.Database) error {
+	_, err := QAEngineDatabase.Collection("questions").UpdateMany(ctx, bson.M{
+		"answercount": bson.M{"$exists": false},
+	}, bson.A{
+		bson.M{"$set": bson.M{
+			"answercount":  bson.M{"$size": bson.M{"$ifNull": bson.A{"$answers", bson.A{}}}},
+			"lastanswerat": bson.M{"$ifNull": bson.A{bson.M{"$max": "$answers.dateposted"}, time.Time{}}},
+			"upvotes":      bson.M{"$max": bson.A{"$votes", 0}},
+			"downvotes":    bson.M{"$max": bson.A{bson.M{"$multiply": bson.A{"$votes", -1}}, 0}},
+			"controversy":  0,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = QAEngineDatabase.Collection("questions").UpdateMany(ctx, bson.M{
+		"hot": bson.M{"$exists": false},
+	}, bson.A{
+		bson.M{"$set": bson.M{"hot": mongoHotScore()}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The _id holds the time the question was asked, its latest answer is the last activity known
+	_, err = QAEngineDatabase.Collection("questions").UpdateMany(ctx, bson.M{
+		"createdat": bson.M{"$exists": false},
+	}, bson.A{
+		bson.M{"$set": bson.M{
+			"createdat": bson.M{"$toDate": "$_id"},
+			"updatedat": bson.M{"$toDate": "$_id"},
+		}},
+		bson.M{"$set": bson.M{
+			"lastactivityat": bson.M{"$max": bson.A{"$createdat", "$lastanswerat"}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = QAEngineDatabase.Collection("questions").UpdateMany(ctx, bson.M{
+		"status.state": bson.M{"$exists": false},
+	}, bson.M{
+		"$set": bson.M{"status": model.QuestionStatus{State: model.QuestionOpen}},
+	})
+	if err != nil {
+		return err
+	}
+	return migrateAnswerIDs(ctx, QAEngineDatabase.Collection("questions"))
+}
+
+// Reports whether the answer document has no id yet
+func missingAnswerID(answer bson.M) bool {
+	id, ok := answer["id"].(primitive.ObjectID)
+	return !ok || id.IsZero()
+}
+
+// Gives an id to the answers stored before answers had one, so they can be accepted, voted on, edited
+// and deleted one at a time. The accepted answer copy gets the id of the answer marked as selected.
+// Ids cannot be generated inside an update pipeline, so every question is rewritten on its own
+func migrateAnswerIDs(ctx context.Context, questions *mongo.Collection) error {
+	zeroID := primitive.ObjectID{}
+	cursor, err := questions.Find(ctx, bson.M{
+		"answers": bson.M{"$elemMatch": bson.M{"id": bson.M{"$in": bson.A{nil, zeroID}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var question struct {
+			ID             primitive.ObjectID `bson:"_id"`
+			Answers        []bson.M           `bson:"answers"`
+			SelectedAnswer bson.M             `bson:"selectedanswer"`
+		}
+		if err = cursor.Decode(&question); err != nil {
+			return err
+		}
+
+		set := bson.M{"answers": question.Answers}
+		for _, answer := range question.Answers {
+			if missingAnswerID(answer) {
+				answer["id"] = primitive.NewObjectID()
+			}
+			if selected, _ := answer["isselected"].(bool); selected && question.SelectedAnswer != nil && missingAnswerID(question.SelectedAnswer) {
+				set["selectedanswer.id"] = answer["id"]
+			}
+		}
+		if _, err = questions.UpdateOne(ctx, bson.M{"_id": question.ID}, bson.M{"$set": set}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// MigrateLegacyVotes moves the per user vote histories of the votes collection to one uservotes record
+// per vote. Votes recorded by title only are matched to the question with that title. A user who already
+// holds a record on the target keeps it, and every history is deleted once it was moved, so the migration
+// runs at most once per history
+func MigrateLegacyVotes(ctx context.Context, QAEngineDatabase *mongo.Database) error {
+	legacy := QAEngineDatabase.Collection("votes")
+	questions := &mongoQuestionStore{collection: QAEngineDatabase.Collection("questions")}
+	records := QAEngineDatabase.Collection("uservotes")
+
+	cursor, err := legacy.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var history model.Votes
+		if err = cursor.Decode(&history); err != nil {
+			return err
+		}
+
+		lists := []struct {
+			vote string
+			docs []model.VoteDoc
+		}{{model.VoteUp, history.Upvotes}, {model.VoteDown, history.Downvotes}}
+
+		for _, list := range lists {
+			for _, doc := range list.docs {
+				if doc.QuestionID.IsZero() {
+					question, err := questions.FindByTitle(ctx, doc.Title)
+					if err == ErrNotFound {
+						continue
+					} else if err != nil {
+						return err
+					}
+					doc.QuestionID = question.ID
+				}
+
+				_, err = records.InsertOne(ctx, model.Vote{
+					Key:   model.VoteKey{Username: history.Username, QuestionID: doc.QuestionID, AnswerID: doc.AnswerID},
+					Email: history.Email,
+					Title: doc.Title,
+					Vote:  list.vote,
+					Date:  doc.Date,
+				})
+				if err != nil && !mongo.IsDuplicateKeyError(err) {
+					return err
+				}
+			}
+		}
+
+		if _, err = legacy.DeleteOne(ctx, bson.M{"_id": history.ID}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Translates the driver sentinel error into the store one
+func mongoError(err error) error {
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	return err
+}
+
+type mongoUserStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoUserStore) findOne(ctx context.Context, filter bson.M) (model.UserReturnModel, error) {
+	var user model.UserReturnModel
+	err := s.collection.FindOne(ctx, filter).Decode(&user)
+	return user, mongoError(err)
+}
+
+func (s *mongoUserStore) FindByEmail(ctx context.Context, email string) (model.UserReturnModel, error) {
+	return s.findOne(ctx, bson.M{"email": email})
+}
+
+func (s *mongoUserStore) FindByUsername(ctx context.Context, username string) (model.UserReturnModel, error) {
+	return s.findOne(ctx, bson.M{"username": username})
+}
+
+func (s *mongoUserStore) FindByUsernameAndEmail(ctx context.Context, username string, email string) (model.UserReturnModel, error) {
+	return s.findOne(ctx, bson.M{"username": username, "email": email})
+}
+
+func (s *mongoUserStore) Insert(ctx context.Context, user *model.UserModel) error {
+	_, err := s.collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *mongoUserStore) AddReputation(ctx context.Context, username string, delta int) error {
+	result, err := s.collection.UpdateOne(ctx, bson.M{"username": username}, bson.M{"$inc": bson.M{"reputation": delta}})
+	if err == nil && result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *mongoUserStore) SetReputation(ctx context.Context, username string, reputation int) error {
+	result, err := s.collection.UpdateOne(ctx, bson.M{"username": username}, bson.M{"$set": bson.M{"reputation": reputation}})
+	if err == nil && result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *mongoUserStore) Reputations(ctx context.Context, usernames []string) (map[string]int, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"username": bson.M{"$in": usernames}},
+		options.Find().SetProjection(bson.M{"username": 1, "reputation": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []model.UserReturnModel
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	reputations := map[string]int{}
+	for _, user := range users {
+		reputations[user.Username] = user.Reputation
+	}
+	return reputations, nil
+}
+
+type mongoQuestionStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoQuestionStore) findOne(ctx context.Context, filter bson.M) (model.Question, error) {
+	var question model.Question
+	err := s.collection.FindOne(ctx, filter).Decode(&question)
+	return question, mongoError(err)
+}
+
+func (s *mongoQuestionStore) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]model.Question, error) {
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	questions := []model.Question{}
+	if err = cursor.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+func (s *mongoQuestionStore) FindByID(ctx context.Context, id primitive.ObjectID) (model.Question, error) {
+	return s.findOne(ctx, bson.M{"_id": id})
+}
+
+func (s *mongoQuestionStore) FindByTitle(ctx context.Context, title string) (model.Question, error) {
+	return s.findOne(ctx, bson.M{"title": title})
+}
+
+func (s *mongoQuestionStore) FindByUsernameAndTitle(ctx context.Context, username string, title string) (model.Question, error) {
+	return s.findOne(ctx, bson.M{"username": username, "title": title})
+}
+
+func (s *mongoQuestionStore) Insert(ctx context.Context, question *model.Question) error {
+	if question.ID.IsZero() {
+		question.ID = primitive.NewObjectID()
+	}
+	question.Hot = hotScore(question.Votes, question.AnswerCount, question.ID.Timestamp())
+	_, err := s.collection.InsertOne(ctx, question)
+	return err
+}
+
+func (s *mongoQuestionStore) AddAnswer(ctx context.Context, id primitive.ObjectID, answer model.Answer) error {
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.A{
+		bson.M{"$set": bson.M{
+			"answers":        bson.M{"$concatArrays": bson.A{bson.M{"$ifNull": bson.A{"$answers", bson.A{}}}, bson.A{bson.M{"$literal": answer}}}},
+			"answercount":    bson.M{"$add": bson.A{"$answercount", 1}},
+			"lastanswerat":   answer.DatePosted,
+			"lastactivityat": answer.DatePosted,
+		}},
+		bson.M{"$set": bson.M{"hot": mongoHotScore()}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mongoQuestionStore) IncrementVotes(ctx context.Context, id primitive.ObjectID, upvotes int, downvotes int) error {
+	// The controversy is computed from the new counts in the same update, see controversy
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.A{
+		bson.M{"$set": bson.M{
+			"upvotes":        bson.M{"$add": bson.A{"$upvotes", upvotes}},
+			"downvotes":      bson.M{"$add": bson.A{"$downvotes", downvotes}},
+			"votes":          bson.M{"$add": bson.A{"$votes", upvotes - downvotes}},
+			"lastactivityat": time.Now(),
+		}},
+		bson.M{"$set": bson.M{
+			"controversy": bson.M{"$cond": bson.A{
+				bson.M{"$and": bson.A{bson.M{"$gt": bson.A{"$upvotes", 0}}, bson.M{"$gt": bson.A{"$downvotes", 0}}}},
+				bson.M{"$pow": bson.A{
+					bson.M{"$add": bson.A{"$upvotes", "$downvotes"}},
+					bson.M{"$divide": bson.A{
+						bson.M{"$min": bson.A{"$upvotes", "$downvotes"}},
+						bson.M{"$max": bson.A{"$upvotes", "$downvotes"}},
+					}},
+				}},
+				0,
+			}},
+			"hot": mongoHotScore(),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mongoQuestionStore) IncrementAnswerVotes(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, delta int) error {
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id, "answers.id": answerID}, bson.M{
+		"$inc": bson.M{"answers.$.votes": delta},
+		"$set": bson.M{"lastactivityat": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	// Keep the copy of the accepted answer in step
+	_, err = s.collection.UpdateOne(ctx, bson.M{"_id": id, "selectedanswer.id": answerID}, bson.M{
+		"$inc": bson.M{"selectedanswer.votes": delta},
+	})
+	return err
+}
+
+func (s *mongoQuestionStore) SetSelectedAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID) (model.Question, error) {
+	filter := bson.M{"_id": id}
+	if !answerID.IsZero() {
+		filter["answers.id"] = answerID
+	}
+
+	// A single pipeline update flips every isselected flag and copies the accepted answer,
+	// so the two fields can never disagree
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"answers": bson.M{"$map": bson.M{
+				"input": "$answers",
+				"as":    "answer",
+				"in": bson.M{"$mergeObjects": bson.A{"$$answer", bson.M{
+					"isselected": bson.M{"$eq": bson.A{"$$answer.id", answerID}},
+				}}},
+			}},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"lastactivityat": time.Now(),
+			"selectedanswer": bson.M{"$ifNull": bson.A{
+				bson.M{"$arrayElemAt": bson.A{bson.M{"$filter": bson.M{
+					"input": "$answers",
+					"as":    "answer",
+					"cond":  "$$answer.isselected",
+				}}, 0}},
+				bson.M{"$literal": model.Answer{}},
+			}},
+		}}},
+	}
+
+	var question model.Question
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&question)
+	return question, mongoError(err)
+}
+
+func (s *mongoQuestionStore) List(ctx context.Context, query QuestionQuery) ([]model.Question, bool, error) {
+	sort, filter := mongoQuestionQuery(query)
+	questions, err := s.find(ctx, filter, options.Find().SetSort(sort).SetLimit(int64(query.Limit)+1))
+	if err != nil {
+		return nil, false, err
+	}
+	// One question more than asked for tells whether another page follows
+	if len(questions) > query.Limit {
+		return questions[:query.Limit], true, nil
+	}
+	return questions, false, nil
+}
+
+func (s *mongoQuestionStore) PopularTags(ctx context.Context, limit int) ([]model.TagCount, error) {
+	cursor, err := s.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status.state": mongoStateFilter(nil)}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tags := []model.TagCount{}
+	if err = cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *mongoQuestionStore) TagStats(ctx context.Context, tag string) (model.TagStats, error) {
+	weekAgo := time.Now().AddDate(0, 0, -7)
+	cursor, err := s.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tags": tag, "status.state": mongoStateFilter(nil)}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":               nil,
+			"questions":         bson.M{"$sum": 1},
+			"unanswered":        bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$answercount", 0}}, 1, 0}}},
+			"answers":           bson.M{"$sum": "$answercount"},
+			"votes":             bson.M{"$sum": "$votes"},
+			"questionsthisweek": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$createdat", weekAgo}}, 1, 0}}},
+			"lastusedat":        bson.M{"$max": "$createdat"},
+		}}},
+	})
+	if err != nil {
+		return model.TagStats{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats model.TagStats
+	if cursor.Next(ctx) {
+		err = cursor.Decode(&stats)
+	}
+	if err == nil {
+		err = cursor.Err()
+	}
+	return stats, err
+}
+
+func (s *mongoQuestionStore) RenameTag(ctx context.Context, from string, to string) (int, error) {
+	// Questions already filed under both only lose the old tag
+	pulled, err := s.collection.UpdateMany(ctx, bson.M{"tags": bson.M{"$all": bson.A{from, to}}}, bson.M{
+		"$pull": bson.M{"tags": from},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	renamed, err := s.collection.UpdateMany(ctx, bson.M{"tags": from}, bson.M{
+		"$set": bson.M{"tags.$": to},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(pulled.ModifiedCount + renamed.ModifiedCount), nil
+}
+
+func (s *mongoQuestionStore) Edit(ctx context.Context, id primitive.ObjectID, title string, content string, tags []string) error {
+	now := time.Now()
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"title": title, "content": content, "tags": tags, "updatedat": now, "lastactivityat": now},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mongoQuestionStore) EditAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, text string) error {
+	now := time.Now()
+	// Answers stored before tombstones existed have no deleted field, $ne matches them as well
+	filter := bson.M{"_id": id, "answers": bson.M{"$elemMatch": bson.M{"id": answerID, "deleted": bson.M{"$ne": true}}}}
+	result, err := s.collection.UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{"answers.$.answer": text, "answers.$.updatedat": now, "lastactivityat": now},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	// Keep the copy of the accepted answer in step
+	_, err = s.collection.UpdateOne(ctx, bson.M{"_id": id, "selectedanswer.id": answerID}, bson.M{
+		"$set": bson.M{"selectedanswer.answer": text, "selectedanswer.updatedat": now},
+	})
+	return err
+}
+
+func (s *mongoQuestionStore) DeleteAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, deletedBy string) (model.Question, error) {
+	filter := bson.M{"_id": id, "answers": bson.M{"$elemMatch": bson.M{"id": answerID, "deleted": bson.M{"$ne": true}}}}
+	return s.setAnswerDeleted(ctx, filter, answerID, true, deletedBy)
+}
+
+func (s *mongoQuestionStore) UndeleteAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID) (model.Question, error) {
+	filter := bson.M{"_id": id, "answers": bson.M{"$elemMatch": bson.M{"id": answerID, "deleted": true}}}
+	return s.setAnswerDeleted(ctx, filter, answerID, false, "")
+}
+
+// A single pipeline update flags the answer, unaccepts it and recounts the answers along with the hot score
+func (s *mongoQuestionStore) setAnswerDeleted(ctx context.Context, filter bson.M, answerID primitive.ObjectID, deleted bool, deletedBy string) (model.Question, error) {
+	now := time.Now()
+	flags := bson.M{"deleted": deleted, "deletedby": deletedBy, "deletedat": time.Time{}}
+	count := 1
+	if deleted {
+		flags = bson.M{"deleted": true, "deletedby": deletedBy, "deletedat": now, "isselected": false}
+		count = -1
+	}
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"answers": bson.M{"$map": bson.M{
+				"input": "$answers",
+				"as":    "answer",
+				"in": bson.M{"$cond": bson.A{
+					bson.M{"$eq": bson.A{"$$answer.id", answerID}},
+					bson.M{"$mergeObjects": bson.A{"$$answer", bson.M{"$literal": flags}}},
+					"$$answer",
+				}},
+			}},
+			"answercount":    bson.M{"$add": bson.A{"$answercount", count}},
+			"lastactivityat": now,
+		}}},
+		{{Key: "$set", Value: bson.M{"hot": mongoHotScore()}}},
+	}
+	if deleted {
+		update = append(update, bson.D{{Key: "$set", Value: bson.M{
+			"selectedanswer": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$selectedanswer.id", answerID}},
+				bson.M{"$literal": model.Answer{}},
+				"$selectedanswer",
+			}},
+		}}})
+	}
+
+	var question model.Question
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&question)
+	return question, mongoError(err)
+}
+
+func (s *mongoQuestionStore) SetStatus(ctx context.Context, id primitive.ObjectID, status model.QuestionStatus) (model.Question, error) {
+	var question model.Question
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"status": status},
+		"$unset": bson.M{"closevotes": ""},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&question)
+	return question, mongoError(err)
+}
+
+// The filter only matches an open question the user has not voted on yet, when it does not match
+// the question is read again to tell why
+func (s *mongoQuestionStore) AddCloseVote(ctx context.Context, id primitive.ObjectID, vote model.CloseVote) (model.Question, error) {
+	var question model.Question
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":                 id,
+		"status.state":        bson.M{"$in": bson.A{model.QuestionOpen, nil}},
+		"closevotes.username": bson.M{"$ne": vote.Username},
+	}, bson.M{
+		"$push": bson.M{"closevotes": vote},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&question)
+	if err != mongo.ErrNoDocuments {
+		return question, err
+	}
+
+	question, err = s.FindByID(ctx, id)
+	if err != nil {
+		return model.Question{}, err
+	}
+	if question.CurrentState() != model.QuestionOpen {
+		return model.Question{}, ErrNotFound
+	}
+	return model.Question{}, ErrDuplicate
+}
+
+type mongoRevisionStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoRevisionStore) Insert(ctx context.Context, revision *model.Revision) error {
+	if revision.ID.IsZero() {
+		revision.ID = primitive.NewObjectID()
+	}
+	_, err := s.collection.InsertOne(ctx, revision)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *mongoRevisionStore) List(ctx context.Context, questionID primitive.ObjectID, answerID primitive.ObjectID) ([]model.Revision, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"questionid": questionID, "answerid": answerID}, options.Find().SetSort(bson.M{"number": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []model.Revision{}
+	if err = cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (s *mongoRevisionStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type mongoReputationStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoReputationStore) Insert(ctx context.Context, entry *model.ReputationEntry) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	_, err := s.collection.InsertOne(ctx, entry)
+	return err
+}
+
+func (s *mongoReputationStore) Last(ctx context.Context, username string, reason string, actor string, questionID primitive.ObjectID, answerID primitive.ObjectID) (model.ReputationEntry, error) {
+	var entry model.ReputationEntry
+	err := s.collection.FindOne(ctx, bson.M{
+		"username":   username,
+		"reason":     reason,
+		"actor":      actor,
+		"questionid": questionID,
+		"answerid":   answerID,
+	}, options.FindOne().SetSort(bson.D{{Key: "createdat", Value: -1}, {Key: "_id", Value: -1}})).Decode(&entry)
+	return entry, mongoError(err)
+}
+
+func (s *mongoReputationStore) Sum(ctx context.Context, username string, reasons []string, since time.Time) (int, error) {
+	cursor, err := s.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"username":  username,
+			"reason":    bson.M{"$in": reasons},
+			"createdat": bson.M{"$gte": since},
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "sum": bson.M{"$sum": "$points"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var sums []struct {
+		Sum int `bson:"sum"`
+	}
+	if err = cursor.All(ctx, &sums); err != nil || len(sums) == 0 {
+		return 0, err
+	}
+	return sums[0].Sum, nil
+}
+
+func (s *mongoReputationStore) List(ctx context.Context, username string) ([]model.ReputationEntry, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"username": username}, options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []model.ReputationEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+type mongoCommentStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoCommentStore) Insert(ctx context.Context, comment *model.Comment) error {
+	if comment.ID.IsZero() {
+		comment.ID = primitive.NewObjectID()
+	}
+	// $addToSet needs an array to add the upvoters to
+	if comment.Upvoters == nil {
+		comment.Upvoters = []string{}
+	}
+	_, err := s.collection.InsertOne(ctx, comment)
+	return err
+}
+
+func (s *mongoCommentStore) FindByID(ctx context.Context, id primitive.ObjectID) (model.Comment, error) {
+	var comment model.Comment
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&comment)
+	return comment, mongoError(err)
+}
+
+func (s *mongoCommentStore) find(ctx context.Context, filter bson.M) ([]model.Comment, error) {
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	comments := []model.Comment{}
+	if err = cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (s *mongoCommentStore) List(ctx context.Context, questionID primitive.ObjectID, answerID primitive.ObjectID) ([]model.Comment, error) {
+	return s.find(ctx, bson.M{"questionid": questionID, "answerid": answerID})
+}
+
+func (s *mongoCommentStore) ListByQuestion(ctx context.Context, questionID primitive.ObjectID) ([]model.Comment, error) {
+	return s.find(ctx, bson.M{"questionid": questionID})
+}
+
+func (s *mongoCommentStore) Edit(ctx context.Context, id primitive.ObjectID, text string, mentions []string) (model.Comment, error) {
+	var comment model.Comment
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"text": text, "mentions": mentions, "updatedat": time.Now()},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&comment)
+	return comment, mongoError(err)
+}
+
+// The filter only matches when the upvote of the user changes, so the count moves with the upvoters
+func (s *mongoCommentStore) Upvote(ctx context.Context, id primitive.ObjectID, username string, upvote bool) (model.Comment, error) {
+	filter := bson.M{"_id": id, "upvoters": bson.M{"$ne": username}}
+	update := bson.M{"$addToSet": bson.M{"upvoters": username}, "$inc": bson.M{"upvotes": 1}}
+	if !upvote {
+		filter = bson.M{"_id": id, "upvoters": username}
+		update = bson.M{"$pull": bson.M{"upvoters": username}, "$inc": bson.M{"upvotes": -1}}
+	}
+	if _, err := s.collection.UpdateOne(ctx, filter, update); err != nil {
+		return model.Comment{}, err
+	}
+	return s.FindByID(ctx, id)
+}
+
+type mongoVoteStore struct {
+	collection *mongo.Collection
+}
+
+// The record is keyed by its _id, so the replace and the delete are single document operations and
+// concurrent swaps on the same target are serialized by Mongo. Each of them sees the vote the one
+// before it left behind
+func (s *mongoVoteStore) Swap(ctx context.Context, vote model.Vote) (string, error) {
+	filter := bson.M{"_id": vote.Key}
+
+	var result *mongo.SingleResult
+	if vote.Vote == model.VoteNone {
+		result = s.collection.FindOneAndDelete(ctx, filter)
+	} else {
+		result = s.collection.FindOneAndReplace(ctx, filter, vote, options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.Before))
+	}
+
+	var previous model.Vote
+	err := result.Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		return model.VoteNone, nil
+	} else if mongo.IsDuplicateKeyError(err) {
+		// Two upserts of a new record raced. Inside a transaction the write aborted it, so the caller
+		// retries from the start
+		return "", ErrDuplicate
+	} else if err != nil {
+		return "", err
+	}
+	return previous.Vote, nil
+}
+
+func (s *mongoVoteStore) List(ctx context.Context, questionID primitive.ObjectID, answerID primitive.ObjectID) ([]model.Vote, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"_id.questionid": questionID, "_id.answerid": answerID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	votes := []model.Vote{}
+	if err = cursor.All(ctx, &votes); err != nil {
+		return nil, err
+	}
+	return votes, nil
+}
+
+type mongoTagStore struct {
+	collection *mongo.Collection
+	synonyms   *mongo.Collection
+}
+
+func (s *mongoTagStore) FindByName(ctx context.Context, name string) (model.Tag, error) {
+	var tag model.Tag
+	err := s.collection.FindOne(ctx, bson.M{"_id": name}).Decode(&tag)
+	return tag, mongoError(err)
+}
+
+func (s *mongoTagStore) EnsureTags(ctx context.Context, names []string) error {
+	for _, name := range names {
+		_, err := s.collection.UpdateOne(ctx, bson.M{"_id": name}, bson.M{
+			"$setOnInsert": bson.M{"excerpt": "", "wiki": "", "createdat": time.Now()},
+		}, options.Update().SetUpsert(true))
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *mongoTagStore) UpdateWiki(ctx context.Context, name string, excerpt string, wiki string, editor string) (model.Tag, error) {
+	var tag model.Tag
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": name}, bson.M{
+		"$set": bson.M{"excerpt": excerpt, "wiki": wiki, "editedby": editor, "editedat": time.Now()},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&tag)
+	return tag, mongoError(err)
+}
+
+func (s *mongoTagStore) FindSynonym(ctx context.Context, source string) (model.TagSynonym, error) {
+	var synonym model.TagSynonym
+	err := s.synonyms.FindOne(ctx, bson.M{"_id": source}).Decode(&synonym)
+	return synonym, mongoError(err)
+}
+
+func (s *mongoTagStore) findSynonyms(ctx context.Context, filter bson.M) ([]model.TagSynonym, error) {
+	cursor, err := s.synonyms.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	synonyms := []model.TagSynonym{}
+	if err = cursor.All(ctx, &synonyms); err != nil {
+		return nil, err
+	}
+	return synonyms, nil
+}
+
+func (s *mongoTagStore) FindSynonymsByTarget(ctx context.Context, target string) ([]model.TagSynonym, error) {
+	return s.findSynonyms(ctx, bson.M{"target": target})
+}
+
+func (s *mongoTagStore) FindSynonymsByStatus(ctx context.Context, status string) ([]model.TagSynonym, error) {
+	return s.findSynonyms(ctx, bson.M{"status": status})
+}
+
+func (s *mongoTagStore) InsertSynonym(ctx context.Context, synonym *model.TagSynonym) error {
+	_, err := s.synonyms.InsertOne(ctx, synonym)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *mongoTagStore) ReviewSynonym(ctx context.Context, source string, status string, reviewer string) (model.TagSynonym, error) {
+	var synonym model.TagSynonym
+	err := s.synonyms.FindOneAndUpdate(ctx, bson.M{"_id": source, "status": model.SynonymPending}, bson.M{
+		"$set": bson.M{"status": status, "reviewedby": reviewer, "reviewedat": time.Now()},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&synonym)
+	return synonym, mongoError(err)
+}
+
+func (s *mongoTagStore) ReplaceSynonym(ctx context.Context, synonym *model.TagSynonym) error {
+	_, err := s.synonyms.ReplaceOne(ctx, bson.M{"_id": synonym.Source}, synonym, options.Replace().SetUpsert(true))
+	return err
+}
+
+type mongoTokenStore struct {
+	refreshTokens *mongo.Collection
+	revokedTokens *mongo.Collection
+}
+
+func (s *mongoTokenStore) InsertRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	_, err := s.refreshTokens.InsertOne(ctx, token)
+	return err
+}
+
+func (s *mongoTokenStore) ConsumeRefreshToken(ctx context.Context, id string) (model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := s.refreshTokens.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"used": true},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&token)
+	return token, mongoError(err)
+}
+
+func (s *mongoTokenStore) RevokeRefreshFamily(ctx context.Context, family string) error {
+	_, err := s.refreshTokens.UpdateMany(ctx, bson.M{"family": family}, bson.M{
+		"$set": bson.M{"used": true},
+	})
+	return err
+}
+
+func (s *mongoTokenStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := s.revokedTokens.UpdateOne(ctx, bson.M{"_id": jti}, bson.M{
+		"$set": bson.M{"expiresat": expiresAt},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (s *mongoTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	count, err := s.revokedTokens.CountDocuments(ctx, bson.M{"_id": jti}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
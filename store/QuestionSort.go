@@ -0,0 +1,289 @@
+package store
+
+import (
+	"bytes"
+	"math"
+	"time"
+
+	"example.org/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The orders questions can be listed in. Ties are broken by the question ID, so every order is total
+// and a page boundary never moves
+const (
+	// SortNewest orders the questions by the time they were asked. Ascending, new questions land after every cursor
+	SortNewest = "newest"
+	// SortTop orders the questions by their vote count
+	SortTop = "top"
+	// SortActive orders the questions by the time of their latest answer
+	SortActive = "active"
+	// SortUnanswered lists only the questions without answers, by the time they were asked
+	SortUnanswered = "unanswered"
+	// SortMostAnswered orders the questions by their number of answers
+	SortMostAnswered = "most-answered"
+	// SortControversial orders the questions by how many votes they drew and how evenly those are split
+	SortControversial = "controversial"
+	// SortHot orders the questions by their hot score, which weighs their votes and answers against their age
+	SortHot = "hot"
+)
+
+// How a sort order reads its key off a question and where Mongo finds it
+type questionSort struct {
+	// Field holding the sort key, the ID alone when empty
+	field string
+	// Dates are keyed by their Unix time in milliseconds, the precision Mongo stores them with
+	date bool
+	key  func(question model.Question) float64
+	// Questions left out of the listing, nil when every question is listed
+	filter bson.M
+	match  func(question model.Question) bool
+}
+
+var questionSorts = map[string]questionSort{
+	SortNewest: {},
+	SortTop: {
+		field: "votes",
+		key:   func(question model.Question) float64 { return float64(question.Votes) },
+	},
+	SortActive: {
+		field: "lastanswerat",
+		date:  true,
+		key:   func(question model.Question) float64 { return float64(question.LastAnswerAt.UnixMilli()) },
+	},
+	SortUnanswered: {
+		filter: bson.M{"answercount": 0},
+		match:  func(question model.Question) bool { return question.AnswerCount == 0 },
+	},
+	SortMostAnswered: {
+		field: "answercount",
+		key:   func(question model.Question) float64 { return float64(question.AnswerCount) },
+	},
+	SortControversial: {
+		field: "controversy",
+		key:   func(question model.Question) float64 { return question.Controversy },
+	},
+	SortHot: {
+		field: "hot",
+		key:   func(question model.Question) float64 { return question.Hot },
+	},
+}
+
+// QuestionSorts lists the names of every sort order
+var QuestionSorts = []string{SortNewest, SortTop, SortActive, SortUnanswered, SortMostAnswered, SortControversial, SortHot}
+
+// IsQuestionSort reports whether sort names a known sort order
+func IsQuestionSort(sort string) bool {
+	_, found := questionSorts[sort]
+	return found
+}
+
+// QuestionCursor is the position of a question in a listing, the sort key of the question and its ID
+type QuestionCursor struct {
+	Sort       string             `json:"sort"`
+	Descending bool               `json:"desc,omitempty"`
+	Key        float64            `json:"key,omitempty"`
+	ID         primitive.ObjectID `json:"id"`
+}
+
+// CursorOf returns the position of the question in the listing selected by query
+func CursorOf(query QuestionQuery, question model.Question) QuestionCursor {
+	cursor := QuestionCursor{Sort: query.Sort, Descending: query.Descending, ID: question.ID}
+	if sort := questionSorts[query.Sort]; sort.key != nil {
+		cursor.Key = sort.key(question)
+	}
+	return cursor
+}
+
+// QuestionQuery selects a page of questions
+type QuestionQuery struct {
+	Sort       string
+	Descending bool
+	Limit      int
+	// After is the cursor of the last question of the previous page, nil for the first page
+	After *QuestionCursor
+	// Only the questions asked from Since on and before Until are listed, zero times leave the range open
+	Since time.Time
+	Until time.Time
+	// Only the questions filed under every one of Tags are listed, or under any of them with AnyTag
+	Tags   []string
+	AnyTag bool
+	// Only the questions in one of States are listed, every question but the deleted ones when empty
+	States []string
+	// Only the questions with pending close votes are listed
+	CloseVoted bool
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, candidate := range tags {
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether the question has the close votes the query asks for
+func (query QuestionQuery) hasCloseVotes(question model.Question) bool {
+	return !query.CloseVoted || len(question.CloseVotes) > 0
+}
+
+// Reports whether the question is in one of the states of the query
+func (query QuestionQuery) hasState(question model.Question) bool {
+	if len(query.States) == 0 {
+		return question.CurrentState() != model.QuestionDeleted
+	}
+	return containsTag(query.States, question.CurrentState())
+}
+
+// Reports whether the question is filed under the tags of the query
+func (query QuestionQuery) hasTags(question model.Question) bool {
+	if len(query.Tags) == 0 {
+		return true
+	}
+	matched := 0
+	for _, tag := range query.Tags {
+		if containsTag(question.Tags, tag) {
+			matched++
+		}
+	}
+	if query.AnyTag {
+		return matched > 0
+	}
+	return matched == len(query.Tags)
+}
+
+// Reports whether the question was asked in the time range of the query
+func (query QuestionQuery) inRange(question model.Question) bool {
+	if !query.Since.IsZero() && question.CreatedAt.Before(query.Since) {
+		return false
+	}
+	return query.Until.IsZero() || question.CreatedAt.Before(query.Until)
+}
+
+// Reddit's controversy score, the number of votes raised to the power of how balanced they are
+func controversy(upvotes int, downvotes int) float64 {
+	if upvotes <= 0 || downvotes <= 0 {
+		return 0
+	}
+	balance := float64(downvotes) / float64(upvotes)
+	if upvotes < downvotes {
+		balance = float64(upvotes) / float64(downvotes)
+	}
+	return math.Pow(float64(upvotes+downvotes), balance)
+}
+
+// The hot score is the one of Reddit. Its first term is the order of magnitude of the score of the question,
+// its second the time the question was asked, counted from hotEpoch in hotDecay periods. A question needs
+// ten times the score to rank level with one asked a period later. As the time term never changes, the
+// score only has to be computed again when the votes or the answers of the question change
+var hotEpoch = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	hotDecay = 12 * time.Hour
+	// Every answer weighs as much as an upvote
+	hotAnswerWeight = 1
+)
+
+func hotScore(votes int, answers int, asked time.Time) float64 {
+	score := float64(votes + answers*hotAnswerWeight)
+	order := math.Log10(math.Max(math.Abs(score), 1))
+	if score < 0 {
+		order = -order
+	}
+	return order + asked.Sub(hotEpoch).Seconds()/hotDecay.Seconds()
+}
+
+// hotScore as a Mongo expression over the fields of the question. The _id holds the time it was asked
+func mongoHotScore() bson.M {
+	score := bson.M{"$add": bson.A{"$votes", bson.M{"$multiply": bson.A{"$answercount", hotAnswerWeight}}}}
+	order := bson.M{"$log10": bson.M{"$max": bson.A{bson.M{"$abs": score}, 1}}}
+	sign := bson.M{"$cond": bson.A{bson.M{"$lt": bson.A{score, 0}}, -1, 1}}
+	age := bson.M{"$subtract": bson.A{bson.M{"$toDate": "$_id"}, hotEpoch}}
+	return bson.M{"$add": bson.A{
+		bson.M{"$multiply": bson.A{sign, order}},
+		bson.M{"$divide": bson.A{age, hotDecay.Milliseconds()}},
+	}}
+}
+
+// Reports whether the question at cursor a comes before the one at b in their listing
+func cursorBefore(a QuestionCursor, b QuestionCursor) bool {
+	order := bytes.Compare(a.ID[:], b.ID[:])
+	if a.Key < b.Key {
+		order = -1
+	} else if a.Key > b.Key {
+		order = 1
+	}
+	if a.Descending {
+		return order > 0
+	}
+	return order < 0
+}
+
+// Matches the states like QuestionQuery.hasState, questions without a state are open
+func mongoStateFilter(states []string) bson.M {
+	if len(states) == 0 {
+		return bson.M{"$ne": model.QuestionDeleted}
+	}
+	in := bson.A{}
+	for _, state := range states {
+		in = append(in, state)
+		if state == model.QuestionOpen {
+			in = append(in, nil)
+		}
+	}
+	return bson.M{"$in": in}
+}
+
+// The Mongo sort and filter selecting the page of query
+func mongoQuestionQuery(query QuestionQuery) (bson.D, bson.M) {
+	sort := questionSorts[query.Sort]
+	direction, after := 1, "$gt"
+	if query.Descending {
+		direction, after = -1, "$lt"
+	}
+
+	filter := bson.M{}
+	for field, value := range sort.filter {
+		filter[field] = value
+	}
+	createdAt := bson.M{}
+	if !query.Since.IsZero() {
+		createdAt["$gte"] = query.Since
+	}
+	if !query.Until.IsZero() {
+		createdAt["$lt"] = query.Until
+	}
+	if len(createdAt) > 0 {
+		filter["createdat"] = createdAt
+	}
+	if len(query.Tags) > 0 && query.AnyTag {
+		filter["tags"] = bson.M{"$in": query.Tags}
+	} else if len(query.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": query.Tags}
+	}
+	filter["status.state"] = mongoStateFilter(query.States)
+	if query.CloseVoted {
+		filter["closevotes.0"] = bson.M{"$exists": true}
+	}
+
+	if sort.field == "" {
+		if query.After != nil {
+			filter["_id"] = bson.M{after: query.After.ID}
+		}
+		return bson.D{{Key: "_id", Value: direction}}, filter
+	}
+
+	if query.After != nil {
+		var key interface{} = query.After.Key
+		if sort.date {
+			key = primitive.DateTime(query.After.Key)
+		}
+		filter["$or"] = bson.A{
+			bson.M{sort.field: bson.M{after: key}},
+			bson.M{sort.field: key, "_id": bson.M{after: query.After.ID}},
+		}
+	}
+	return bson.D{{Key: sort.field, Value: direction}, {Key: "_id", Value: direction}}, filter
+}
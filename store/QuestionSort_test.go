@@ -0,0 +1,274 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"example.org/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var asked = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// Inserts a question asked the given number of hours after asked, with the given votes
+func insertQuestion(t *testing.T, questions QuestionStore, title string, hours int, votes int) model.Question {
+	t.Helper()
+
+	createdAt := asked.Add(time.Duration(hours) * time.Hour)
+	question := model.Question{
+		ID:        primitive.NewObjectIDFromTimestamp(createdAt),
+		Title:     title,
+		CreatedAt: createdAt,
+	}
+	if err := questions.Insert(context.Background(), &question); err != nil {
+		t.Fatal(err)
+	}
+	upvotes, downvotes := votes, 0
+	if votes < 0 {
+		upvotes, downvotes = 0, -votes
+	}
+	if err := questions.IncrementVotes(context.Background(), question.ID, upvotes, downvotes); err != nil {
+		t.Fatal(err)
+	}
+	question, err := questions.FindByID(context.Background(), question.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return question
+}
+
+// Lists every page of the query, returning the titles in the order they were listed
+func listAll(t *testing.T, questions QuestionStore, query QuestionQuery) []string {
+	t.Helper()
+
+	titles := []string{}
+	for pages := 0; pages < 100; pages++ {
+		page, hasMore, err := questions.List(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, question := range page {
+			titles = append(titles, question.Title)
+		}
+		if !hasMore {
+			return titles
+		}
+		cursor := CursorOf(query, page[len(page)-1])
+		query.After = &cursor
+	}
+	t.Fatal("listing never ended")
+	return nil
+}
+
+func equalTitles(a []string, b []string) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// Paging through a listing returns every question once, in the order of a single page holding them all
+func TestCursorPaging(t *testing.T) {
+	questions := NewMemoryStore().Questions
+	// Equal votes make the ID break the ties
+	for i, votes := range []int{3, 1, 3, 0, 3, -2, 1} {
+		insertQuestion(t, questions, fmt.Sprintf("q%d", i), i, votes)
+	}
+
+	for _, descending := range []bool{false, true} {
+		for _, sort := range []string{SortNewest, SortTop} {
+			whole := listAll(t, questions, QuestionQuery{Sort: sort, Descending: descending, Limit: 100})
+			for limit := 1; limit <= 3; limit++ {
+				paged := listAll(t, questions, QuestionQuery{Sort: sort, Descending: descending, Limit: limit})
+				if !equalTitles(paged, whole) {
+					t.Errorf("%s desc=%v limit %d: pages %v, want %v", sort, descending, limit, paged, whole)
+				}
+			}
+		}
+	}
+}
+
+// Questions added, or voted up, between two pages leave the rest of the listing in place
+func TestCursorStability(t *testing.T) {
+	questions := NewMemoryStore().Questions
+	for i := 0; i < 4; i++ {
+		insertQuestion(t, questions, fmt.Sprintf("q%d", i), i, 0)
+	}
+
+	query := QuestionQuery{Sort: SortNewest, Limit: 2}
+	first, _, err := questions.List(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor := CursorOf(query, first[len(first)-1])
+	query.After = &cursor
+
+	// Asked after every listed question, it lands on a later page
+	insertQuestion(t, questions, "q4", 10, 0)
+	rest := listAll(t, questions, query)
+	if want := []string{"q2", "q3", "q4"}; !equalTitles(rest, want) {
+		t.Errorf("pages after the first: %v, want %v", rest, want)
+	}
+
+	// A listed question voted further up does not shift the ones still to come
+	top := QuestionQuery{Sort: SortTop, Descending: true, Limit: 2}
+	first, _, err = questions.List(context.Background(), top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor = CursorOf(top, first[len(first)-1])
+	top.After = &cursor
+	if err = questions.IncrementVotes(context.Background(), first[1].ID, 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	rest = listAll(t, questions, top)
+	if want := []string{"q2", "q1", "q0"}; !equalTitles(rest, want) {
+		t.Errorf("pages after a vote: %v, want %v", rest, want)
+	}
+}
+
+// Time ranges narrow the listing without breaking the paging
+func TestQueryFilters(t *testing.T) {
+	questions := NewMemoryStore().Questions
+	for i := 0; i < 5; i++ {
+		insertQuestion(t, questions, fmt.Sprintf("q%d", i), i*24, 0)
+	}
+
+	query := QuestionQuery{Sort: SortNewest, Limit: 1, Since: asked.Add(24 * time.Hour), Until: asked.Add(72 * time.Hour)}
+	if titles := listAll(t, questions, query); !equalTitles(titles, []string{"q1", "q2"}) {
+		t.Errorf("since and until: %v, want [q1 q2]", titles)
+	}
+}
+
+// Adds answers to the question, the last one posted the given number of hours after asked
+func answerQuestion(t *testing.T, questions QuestionStore, question model.Question, answers int, hours int) {
+	t.Helper()
+
+	for i := 0; i < answers; i++ {
+		answer := model.Answer{ID: primitive.NewObjectID(), DatePosted: asked.Add(time.Duration(hours) * time.Hour)}
+		if err := questions.AddAnswer(context.Background(), question.ID, answer); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSortModes(t *testing.T) {
+	questions := NewMemoryStore().Questions
+	insertQuestion(t, questions, "q0", 0, 5)
+	q1 := insertQuestion(t, questions, "q1", 1, 0)
+	q2 := insertQuestion(t, questions, "q2", 2, 0)
+	insertQuestion(t, questions, "q3", 3, 0)
+	// q1 is split evenly, 3 up and 3 down, q2 leans down with 1 up and 2 down
+	if err := questions.IncrementVotes(context.Background(), q1.ID, 3, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := questions.IncrementVotes(context.Background(), q2.ID, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	answerQuestion(t, questions, q1, 2, 5)
+	answerQuestion(t, questions, q2, 1, 10)
+
+	// Highest first, ties broken by the newest ID
+	want := map[string][]string{
+		SortNewest:        {"q3", "q2", "q1", "q0"},
+		SortTop:           {"q0", "q3", "q1", "q2"},
+		SortActive:        {"q2", "q1", "q3", "q0"},
+		SortUnanswered:    {"q3", "q0"},
+		SortMostAnswered:  {"q1", "q2", "q3", "q0"},
+		SortControversial: {"q1", "q2", "q3", "q0"},
+	}
+	for sort, titles := range want {
+		listed := listAll(t, questions, QuestionQuery{Sort: sort, Descending: true, Limit: 2})
+		if !equalTitles(listed, titles) {
+			t.Errorf("%s: %v, want %v", sort, listed, titles)
+		}
+
+		// Ascending is the exact reverse
+		reversed := make([]string, len(titles))
+		for i, title := range titles {
+			reversed[len(titles)-1-i] = title
+		}
+		listed = listAll(t, questions, QuestionQuery{Sort: sort, Limit: 3})
+		if !equalTitles(listed, reversed) {
+			t.Errorf("%s ascending: %v, want %v", sort, listed, reversed)
+		}
+	}
+	for _, sort := range QuestionSorts {
+		if _, tested := want[sort]; !tested && sort != SortHot {
+			t.Errorf("sort %s is not tested", sort)
+		}
+	}
+}
+
+func TestControversy(t *testing.T) {
+	cases := []struct {
+		upvotes, downvotes int
+		want               float64
+	}{
+		{0, 0, 0},
+		{5, 0, 0},
+		{0, 5, 0},
+		{3, 3, 6},
+		// 10 votes, a quarter as many up as down
+		{2, 8, math.Pow(10, 0.25)},
+	}
+	for _, c := range cases {
+		if got := controversy(c.upvotes, c.downvotes); got-c.want > 1e-9 || c.want-got > 1e-9 {
+			t.Errorf("controversy(%d, %d) = %v, want %v", c.upvotes, c.downvotes, got, c.want)
+		}
+	}
+	// The more even the split the higher, at the same number of votes
+	if controversy(5, 5) <= controversy(9, 1) {
+		t.Error("an even split is not more controversial than a lopsided one")
+	}
+}
+
+func TestHotScore(t *testing.T) {
+	near := func(a float64, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+	if score := hotScore(1, 0, hotEpoch); !near(score, 0) {
+		t.Errorf("one vote at the epoch scores %v, want 0", score)
+	}
+	if score := hotScore(0, 0, hotEpoch); !near(score, 0) {
+		t.Errorf("no votes at the epoch scores %v, want 0", score)
+	}
+	if score := hotScore(100, 0, hotEpoch); !near(score, 2) {
+		t.Errorf("100 votes at the epoch score %v, want 2", score)
+	}
+	if score := hotScore(-100, 0, hotEpoch); !near(score, -2) {
+		t.Errorf("-100 votes at the epoch score %v, want -2", score)
+	}
+
+	// Ten times the score makes up for being asked one decay period earlier
+	older := hotScore(1000, 0, asked)
+	newer := hotScore(100, 0, asked.Add(hotDecay))
+	if !near(older, newer) {
+		t.Errorf("1000 votes %v and 100 votes a period later %v should rank level", older, newer)
+	}
+
+	// Answers weigh like upvotes
+	if !near(hotScore(5, 5, asked), hotScore(10, 0, asked)) {
+		t.Error("answers do not weigh like upvotes")
+	}
+}
+
+// The hot listing follows the score as votes and answers come in
+func TestHotOrder(t *testing.T) {
+	questions := NewMemoryStore().Questions
+	// A day older, q0 needs more than a hundred times the votes of q1 to stay ahead
+	q0 := insertQuestion(t, questions, "q0", 0, 50)
+	insertQuestion(t, questions, "q1", 24, 1)
+	insertQuestion(t, questions, "q2", 25, -5)
+
+	query := QuestionQuery{Sort: SortHot, Descending: true, Limit: 10}
+	if listed := listAll(t, questions, query); !equalTitles(listed, []string{"q1", "q0", "q2"}) {
+		t.Errorf("hot: %v, want [q1 q0 q2]", listed)
+	}
+
+	if err := questions.IncrementVotes(context.Background(), q0.ID, 100, 0); err != nil {
+		t.Fatal(err)
+	}
+	if listed := listAll(t, questions, query); !equalTitles(listed, []string{"q0", "q1", "q2"}) {
+		t.Errorf("hot after 100 upvotes on q0: %v, want [q0 q1 q2]", listed)
+	}
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"example.org/model"
+	"example.org/search"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// indexedQuestionStore keeps the search index in step with the questions. After every write the
+// question is read back and indexed again, under a lock so the last write is always the one indexed
+type indexedQuestionStore struct {
+	QuestionStore
+	index *search.Index
+	mu    sync.Mutex
+}
+
+func newIndexedQuestionStore(questions QuestionStore, index *search.Index) *indexedQuestionStore {
+	return &indexedQuestionStore{QuestionStore: questions, index: index}
+}
+
+func (s *indexedQuestionStore) reindex(ctx context.Context, ids ...primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		question, err := s.QuestionStore.FindByID(ctx, id)
+		if err == ErrNotFound {
+			s.index.Remove(id)
+			continue
+		} else if err != nil {
+			return err
+		}
+		// Deleted questions are not searchable
+		if question.CurrentState() == model.QuestionDeleted {
+			s.index.Remove(id)
+			continue
+		}
+		s.index.Put(question)
+	}
+	return nil
+}
+
+func (s *indexedQuestionStore) Insert(ctx context.Context, question *model.Question) error {
+	if err := s.QuestionStore.Insert(ctx, question); err != nil {
+		return err
+	}
+	return s.reindex(ctx, question.ID)
+}
+
+func (s *indexedQuestionStore) AddAnswer(ctx context.Context, id primitive.ObjectID, answer model.Answer) error {
+	if err := s.QuestionStore.AddAnswer(ctx, id, answer); err != nil {
+		return err
+	}
+	return s.reindex(ctx, id)
+}
+
+func (s *indexedQuestionStore) IncrementVotes(ctx context.Context, id primitive.ObjectID, upvotes int, downvotes int) error {
+	if err := s.QuestionStore.IncrementVotes(ctx, id, upvotes, downvotes); err != nil {
+		return err
+	}
+	return s.reindex(ctx, id)
+}
+
+func (s *indexedQuestionStore) SetSelectedAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID) (model.Question, error) {
+	question, err := s.QuestionStore.SetSelectedAnswer(ctx, id, answerID)
+	if err != nil {
+		return question, err
+	}
+	return question, s.reindex(ctx, id)
+}
+
+func (s *indexedQuestionStore) RenameTag(ctx context.Context, from string, to string) (int, error) {
+	renamed, err := s.QuestionStore.RenameTag(ctx, from, to)
+	if err != nil {
+		return renamed, err
+	}
+	return renamed, s.reindex(ctx, s.index.Tagged(from)...)
+}
+
+func (s *indexedQuestionStore) Edit(ctx context.Context, id primitive.ObjectID, title string, content string, tags []string) error {
+	if err := s.QuestionStore.Edit(ctx, id, title, content, tags); err != nil {
+		return err
+	}
+	return s.reindex(ctx, id)
+}
+
+func (s *indexedQuestionStore) EditAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, text string) error {
+	if err := s.QuestionStore.EditAnswer(ctx, id, answerID, text); err != nil {
+		return err
+	}
+	return s.reindex(ctx, id)
+}
+
+func (s *indexedQuestionStore) DeleteAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, deletedBy string) (model.Question, error) {
+	question, err := s.QuestionStore.DeleteAnswer(ctx, id, answerID, deletedBy)
+	if err != nil {
+		return question, err
+	}
+	return question, s.reindex(ctx, id)
+}
+
+func (s *indexedQuestionStore) UndeleteAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID) (model.Question, error) {
+	question, err := s.QuestionStore.UndeleteAnswer(ctx, id, answerID)
+	if err != nil {
+		return question, err
+	}
+	return question, s.reindex(ctx, id)
+}
+
+func (s *indexedQuestionStore) SetStatus(ctx context.Context, id primitive.ObjectID, status model.QuestionStatus) (model.Question, error) {
+	question, err := s.QuestionStore.SetStatus(ctx, id, status)
+	if err != nil {
+		return question, err
+	}
+	return question, s.reindex(ctx, id)
+}
+
+// BuildSearchIndex indexes every stored question, for stores that outlive the process
+func BuildSearchIndex(ctx context.Context, QAEngineStore *Store) error {
+	query := QuestionQuery{Sort: SortNewest, Descending: true, Limit: 500}
+	for {
+		questions, hasMore, err := QAEngineStore.Questions.List(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, question := range questions {
+			QAEngineStore.Search.Put(question)
+		}
+		if !hasMore {
+			return nil
+		}
+		cursor := CursorOf(query, questions[len(questions)-1])
+		query.After = &cursor
+	}
+}
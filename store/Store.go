@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"example.org/model"
+	"example.org/search"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrNotFound is returned by every store when the requested document does not exist
+var ErrNotFound = errors.New("Document not found")
+
+// ErrDuplicate is returned when a document with the same key is already stored
+var ErrDuplicate = errors.New("Document already exists")
+
+// UserStore holds the registered users of the application
+type UserStore interface {
+	FindByEmail(ctx context.Context, email string) (model.UserReturnModel, error)
+	FindByUsername(ctx context.Context, username string) (model.UserReturnModel, error)
+	FindByUsernameAndEmail(ctx context.Context, username string, email string) (model.UserReturnModel, error)
+	// Insert returns ErrDuplicate when the username or the email is already taken
+	Insert(ctx context.Context, user *model.UserModel) error
+	// AddReputation adds delta to the reputation of the user
+	AddReputation(ctx context.Context, username string, delta int) error
+	SetReputation(ctx context.Context, username string, reputation int) error
+	// Reputations returns the reputation of every registered user among usernames
+	Reputations(ctx context.Context, usernames []string) (map[string]int, error)
+}
+
+// QuestionStore holds the questions along with their embedded answers
+type QuestionStore interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (model.Question, error)
+	FindByTitle(ctx context.Context, title string) (model.Question, error)
+	FindByUsernameAndTitle(ctx context.Context, username string, title string) (model.Question, error)
+	// Insert stores the question, generating its ID when it has none
+	Insert(ctx context.Context, question *model.Question) error
+	// AddAnswer appends the answer to the question with the given ID.
+	// This and the vote and accept methods below also record the last activity of the question
+	AddAnswer(ctx context.Context, id primitive.ObjectID, answer model.Answer) error
+	// IncrementVotes adds to the upvote and downvote counts of the question with the given ID,
+	// moving its vote count and controversy along
+	IncrementVotes(ctx context.Context, id primitive.ObjectID, upvotes int, downvotes int) error
+	// IncrementAnswerVotes adds delta to the vote count of an answer of the question
+	IncrementAnswerVotes(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, delta int) error
+	// SetSelectedAnswer accepts the answer with answerID, unselecting every other answer of the question,
+	// and returns the updated question. A zero answerID clears the accepted answer
+	SetSelectedAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID) (model.Question, error)
+	// List returns a page of at most query.Limit questions and whether more follow it
+	List(ctx context.Context, query QuestionQuery) ([]model.Question, bool, error)
+	// PopularTags returns the limit most used tags with the number of questions filed under each
+	PopularTags(ctx context.Context, limit int) ([]model.TagCount, error)
+	// TagStats sums up the questions filed under the tag
+	TagStats(ctx context.Context, tag string) (model.TagStats, error)
+	// RenameTag files every question tagged from under to instead and returns how many were changed
+	RenameTag(ctx context.Context, from string, to string) (int, error)
+	// Edit replaces the title, content and tags of the question, recording the edit as activity
+	Edit(ctx context.Context, id primitive.ObjectID, title string, content string, tags []string) error
+	// EditAnswer replaces the text of an answer that is not deleted
+	EditAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, text string) error
+	// DeleteAnswer turns the answer into a tombstone, no longer counted nor accepted, and returns the
+	// updated question. ErrNotFound is returned when the answer does not exist or is already deleted
+	DeleteAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID, deletedBy string) (model.Question, error)
+	// UndeleteAnswer restores a deleted answer, ErrNotFound when the answer is not deleted
+	UndeleteAnswer(ctx context.Context, id primitive.ObjectID, answerID primitive.ObjectID) (model.Question, error)
+	// SetStatus moves the question to another state, clearing its close votes, and returns the updated question
+	SetStatus(ctx context.Context, id primitive.ObjectID, status model.QuestionStatus) (model.Question, error)
+	// AddCloseVote records the vote on the open question and returns the updated question. ErrDuplicate is
+	// returned when the user already voted to close it, ErrNotFound when it is missing or not open
+	AddCloseVote(ctx context.Context, id primitive.ObjectID, vote model.CloseVote) (model.Question, error)
+}
+
+// TagStore holds the description of every tag in use
+type TagStore interface {
+	FindByName(ctx context.Context, name string) (model.Tag, error)
+	// EnsureTags creates the tags that do not exist yet, leaving the others untouched
+	EnsureTags(ctx context.Context, names []string) error
+	// UpdateWiki replaces the excerpt and wiki of the tag and returns the updated tag
+	UpdateWiki(ctx context.Context, name string, excerpt string, wiki string, editor string) (model.Tag, error)
+
+	FindSynonym(ctx context.Context, source string) (model.TagSynonym, error)
+	// FindSynonymsByTarget returns every synonym of the tag, whatever its status
+	FindSynonymsByTarget(ctx context.Context, target string) ([]model.TagSynonym, error)
+	FindSynonymsByStatus(ctx context.Context, status string) ([]model.TagSynonym, error)
+	// InsertSynonym returns ErrDuplicate when the source already has a synonym
+	InsertSynonym(ctx context.Context, synonym *model.TagSynonym) error
+	// ReviewSynonym moves a pending synonym to status and returns it, ErrNotFound when it is not pending
+	ReviewSynonym(ctx context.Context, source string, status string, reviewer string) (model.TagSynonym, error)
+	// ReplaceSynonym stores the synonym, replacing the one its source had before if any
+	ReplaceSynonym(ctx context.Context, synonym *model.TagSynonym) error
+}
+
+// RevisionStore holds the edit history of the questions and answers.
+// A zero answerID selects the revisions of the question itself
+type RevisionStore interface {
+	// Insert returns ErrDuplicate when the post already has a revision with the same number
+	Insert(ctx context.Context, revision *model.Revision) error
+	// List returns the revisions of the post, oldest first
+	List(ctx context.Context, questionID primitive.ObjectID, answerID primitive.ObjectID) ([]model.Revision, error)
+	// Delete removes the revision, for an edit that could not be applied after its revision was stored
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// CommentStore holds the comments on the questions and answers.
+// A zero answerID selects the comments on the question itself
+type CommentStore interface {
+	// Insert stores the comment, generating its ID when it has none
+	Insert(ctx context.Context, comment *model.Comment) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (model.Comment, error)
+	// List returns the comments on the post, oldest first
+	List(ctx context.Context, questionID primitive.ObjectID, answerID primitive.ObjectID) ([]model.Comment, error)
+	// ListByQuestion returns the comments on the question and on every one of its answers, oldest first
+	ListByQuestion(ctx context.Context, questionID primitive.ObjectID) ([]model.Comment, error)
+	// Edit replaces the text and the mentions of the comment and returns the updated comment
+	Edit(ctx context.Context, id primitive.ObjectID, text string, mentions []string) (model.Comment, error)
+	// Upvote adds or withdraws the upvote of the user and returns the updated comment.
+	// Upvoting twice or withdrawing an upvote the user does not hold changes nothing
+	Upvote(ctx context.Context, id primitive.ObjectID, username string, upvote bool) (model.Comment, error)
+}
+
+// ReputationStore holds the reputation ledger of every user
+type ReputationStore interface {
+	Insert(ctx context.Context, entry *model.ReputationEntry) error
+	// Last returns the latest entry of the user for the reason, caused by the actor on the post,
+	// ErrNotFound when there is none. A zero answerID selects the question itself
+	Last(ctx context.Context, username string, reason string, actor string, questionID primitive.ObjectID, answerID primitive.ObjectID) (model.ReputationEntry, error)
+	// Sum adds up the points of the entries of the user for the reasons from since on
+	Sum(ctx context.Context, username string, reasons []string, since time.Time) (int, error)
+	// List returns the ledger of the user, oldest first
+	List(ctx context.Context, username string) ([]model.ReputationEntry, error)
+}
+
+// VoteStore holds the vote of every user on every question and answer, one record per voter and target
+type VoteStore interface {
+	// Swap atomically replaces the vote recorded under vote.Key and returns the vote it replaced,
+	// VoteNone when there was no record. Swapping in a VoteNone vote removes the record. When another
+	// swap created the record in the meantime it fails with ErrDuplicate, and swapping again replaces it
+	Swap(ctx context.Context, vote model.Vote) (string, error)
+	// List returns the votes held on the post. A zero answerID selects the question itself
+	List(ctx context.Context, questionID primitive.ObjectID, answerID primitive.ObjectID) ([]model.Vote, error)
+}
+
+// TokenStore holds the refresh tokens and the list of revoked access tokens
+type TokenStore interface {
+	InsertRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	// ConsumeRefreshToken marks the refresh token as used and returns it as it was before,
+	// so a Used token in the result means it had already been redeemed
+	ConsumeRefreshToken(ctx context.Context, id string) (model.RefreshToken, error)
+	// RevokeRefreshFamily marks every refresh token of the family as used
+	RevokeRefreshFamily(ctx context.Context, family string) error
+	// Revoke adds the access token to the revocation list until it expires
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// Transactor groups writes to several stores so they land together
+type Transactor interface {
+	// Run calls fn with the context the store methods it calls must be given. When the backend supports
+	// transactions the writes of fn are committed only if it returns nil, and fn may be called again
+	// when the transaction has to be retried
+	Run(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Store bundles every store the handlers need so they can be passed around together
+type Store struct {
+	Users     UserStore
+	Questions QuestionStore
+	Votes     VoteStore
+	Tags      TagStore
+	Revisions RevisionStore
+	Comments  CommentStore
+	// Reputation is the ledger the reputation of the users is the sum of
+	Reputation ReputationStore
+	Tokens     TokenStore
+	// Transactions groups the writes that must not be applied in part, such as a vote and its counts
+	Transactions Transactor
+	// Search indexes the questions for full text search. Questions updates it on every write
+	Search *search.Index
+}
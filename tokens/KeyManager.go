@@ -0,0 +1,207 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// KeyConfig describes a single signing key.
+// HMAC keys use Secret, RSA and EdDSA keys are read from PEM files. A key with only a public
+// key file can verify tokens but never sign them
+type KeyConfig struct {
+	ID             string `json:"kid"`
+	Algorithm      string `json:"alg"`
+	Secret         string `json:"secret"`
+	PrivateKeyFile string `json:"privatekeyfile"`
+	PublicKeyFile  string `json:"publickeyfile"`
+}
+
+// KeysConfig lists every key the server accepts and names the one new tokens are signed with.
+// Keys that are no longer active keep verifying the tokens they signed, which allows rotation
+// without logging every user out
+type KeysConfig struct {
+	Active string      `json:"active"`
+	Keys   []KeyConfig `json:"keys"`
+}
+
+type key struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeyManager signs tokens with the active key and verifies them with whichever key their kid names
+type KeyManager struct {
+	active *key
+	keys   map[string]*key
+}
+
+// NewKeyManager parses every configured key and returns a manager signing with the active one
+func NewKeyManager(config KeysConfig) (*KeyManager, error) {
+	manager := &KeyManager{keys: map[string]*key{}}
+
+	for _, keyConfig := range config.Keys {
+		if keyConfig.ID == "" {
+			return nil, errors.New("Every key needs a kid")
+		}
+		if _, found := manager.keys[keyConfig.ID]; found {
+			return nil, fmt.Errorf("Duplicate key %q", keyConfig.ID)
+		}
+
+		k, err := loadKey(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("Loading key %q: %v", keyConfig.ID, err)
+		}
+		manager.keys[k.id] = k
+	}
+
+	active, found := manager.keys[config.Active]
+	if !found {
+		return nil, fmt.Errorf("Active key %q is not configured", config.Active)
+	}
+	if active.signKey == nil {
+		return nil, fmt.Errorf("Active key %q has no private key to sign with", config.Active)
+	}
+	manager.active = active
+
+	return manager, nil
+}
+
+// LoadKeyManager reads a KeysConfig from the JSON file at path
+func LoadKeyManager(path string) (*KeyManager, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config KeysConfig
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("Parsing %s: %v", path, err)
+	}
+	return NewKeyManager(config)
+}
+
+// NewHMACKeyManager returns a manager with a single HS256 key
+func NewHMACKeyManager(kid string, secret []byte) (*KeyManager, error) {
+	return NewKeyManager(KeysConfig{
+		Active: kid,
+		Keys:   []KeyConfig{{ID: kid, Algorithm: "HS256", Secret: string(secret)}},
+	})
+}
+
+// NewRandomKeyManager returns a manager with a freshly generated HS256 key.
+// Tokens signed with it do not survive a restart of the server
+func NewRandomKeyManager() (*KeyManager, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return NewHMACKeyManager("ephemeral", secret)
+}
+
+func loadKey(config KeyConfig) (*key, error) {
+	k := &key{id: config.ID}
+
+	switch config.Algorithm {
+	case "HS256", "HS384", "HS512":
+		if len(config.Secret) < 32 {
+			return nil, errors.New("HMAC secrets must be at least 32 bytes long")
+		}
+		k.method = jwt.GetSigningMethod(config.Algorithm)
+		k.signKey = []byte(config.Secret)
+		k.verifyKey = k.signKey
+
+	case "RS256", "RS384", "RS512":
+		k.method = jwt.GetSigningMethod(config.Algorithm)
+		if config.PrivateKeyFile != "" {
+			data, err := ioutil.ReadFile(config.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			k.signKey = privateKey
+			k.verifyKey = &privateKey.PublicKey
+		}
+		if config.PublicKeyFile != "" {
+			data, err := ioutil.ReadFile(config.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			k.verifyKey = publicKey
+		}
+
+	case "EdDSA":
+		k.method = SigningMethodEdDSA
+		if config.PrivateKeyFile != "" {
+			data, err := ioutil.ReadFile(config.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, err := ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			k.signKey = privateKey
+			k.verifyKey = privateKey.Public()
+		}
+		if config.PublicKeyFile != "" {
+			data, err := ioutil.ReadFile(config.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, err := ParseEdPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			k.verifyKey = publicKey
+		}
+
+	default:
+		return nil, fmt.Errorf("Unsupported algorithm %q", config.Algorithm)
+	}
+
+	if k.verifyKey == nil {
+		return nil, errors.New("No key material configured")
+	}
+	return k, nil
+}
+
+// Sign returns the claims signed with the active key, its kid is set in the token header
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(m.active.method, claims)
+	token.Header["kid"] = m.active.id
+	return token.SignedString(m.active.signKey)
+}
+
+// Keyfunc picks the verification key named by the kid header of the token.
+// The algorithm of the token must match the one the key was configured with, so an RSA
+// public key can never be used as an HMAC secret
+func (m *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, found := m.keys[kid]
+	if !found {
+		return nil, errors.New("Unknown signing key")
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, errors.New("Unexpected signing method")
+	}
+	return k.verifyKey, nil
+}
+
+// Parse verifies the token string and decodes it into claims
+func (m *KeyManager) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, m.Keyfunc)
+}
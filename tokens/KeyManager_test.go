@@ -0,0 +1,204 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	secretA = "0123456789abcdef0123456789abcdef"
+	secretB = "fedcba9876543210fedcba9876543210"
+)
+
+func hmacKey(kid string, secret string) KeyConfig {
+	return KeyConfig{ID: kid, Algorithm: "HS256", Secret: secret}
+}
+
+func newManager(t *testing.T, config KeysConfig) *KeyManager {
+	t.Helper()
+
+	manager, err := NewKeyManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manager
+}
+
+func sign(t *testing.T, manager *KeyManager) string {
+	t.Helper()
+
+	token, err := manager.Sign(&jwt.StandardClaims{Subject: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// Writes an Ed25519 key pair as PEM files, returning the paths of the private and the public key
+func writeEdKeys(t *testing.T) (string, string) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	privatePath := filepath.Join(dir, "ed.key")
+	publicPath := filepath.Join(dir, "ed.pub")
+	if err = os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateBytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+// Tokens carry the kid of the active key and are verified with the key their kid names
+func TestKidSelection(t *testing.T) {
+	manager := newManager(t, KeysConfig{Active: "b", Keys: []KeyConfig{hmacKey("a", secretA), hmacKey("b", secretB)}})
+
+	tokenString := sign(t, manager)
+	token, err := manager.Parse(tokenString, &jwt.StandardClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := token.Header["kid"]; kid != "b" {
+		t.Errorf("kid = %v, want b", kid)
+	}
+
+	// The same secret under another kid must not verify
+	other := newManager(t, KeysConfig{Active: "a", Keys: []KeyConfig{hmacKey("a", secretB)}})
+	if _, err = other.Parse(tokenString, &jwt.StandardClaims{}); err == nil {
+		t.Error("token with an unknown kid verified")
+	}
+
+	// A kid naming the wrong key fails the signature check
+	swapped := newManager(t, KeysConfig{Active: "b", Keys: []KeyConfig{hmacKey("a", secretB), hmacKey("b", secretA)}})
+	if _, err = swapped.Parse(tokenString, &jwt.StandardClaims{}); err == nil {
+		t.Error("token verified with the key of another kid")
+	}
+}
+
+func TestEdDSAKeys(t *testing.T) {
+	privatePath, publicPath := writeEdKeys(t)
+	signer := newManager(t, KeysConfig{Active: "ed", Keys: []KeyConfig{{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: privatePath}}})
+	verifier := newManager(t, KeysConfig{
+		Active: "h",
+		Keys:   []KeyConfig{hmacKey("h", secretA), {ID: "ed", Algorithm: "EdDSA", PublicKeyFile: publicPath}},
+	})
+
+	if _, err := verifier.Parse(sign(t, signer), &jwt.StandardClaims{}); err != nil {
+		t.Errorf("EdDSA token did not verify with the public key: %v", err)
+	}
+
+	// A key with only its public half can verify but never be the active key
+	_, err := NewKeyManager(KeysConfig{Active: "ed", Keys: []KeyConfig{{ID: "ed", Algorithm: "EdDSA", PublicKeyFile: publicPath}}})
+	if err == nil {
+		t.Error("public only key accepted as the active key")
+	}
+}
+
+// A token whose alg differs from the one its key was configured with is rejected, so a public key
+// can never be used as an HMAC secret
+func TestAlgMismatch(t *testing.T) {
+	_, publicPath := writeEdKeys(t)
+	publicPEM, err := os.ReadFile(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := newManager(t, KeysConfig{
+		Active: "h",
+		Keys:   []KeyConfig{hmacKey("h", secretA), {ID: "ed", Algorithm: "EdDSA", PublicKeyFile: publicPath}},
+	})
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{Subject: "admin"})
+	forged.Header["kid"] = "ed"
+	tokenString, err := forged.SignedString(publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = manager.Parse(tokenString, &jwt.StandardClaims{}); err == nil || !strings.Contains(err.Error(), "Unexpected signing method") {
+		t.Errorf("HS256 token signed with the EdDSA public key: err = %v", err)
+	}
+
+	// Another HMAC size is a mismatch too
+	other := jwt.NewWithClaims(jwt.SigningMethodHS512, &jwt.StandardClaims{Subject: "user"})
+	other.Header["kid"] = "h"
+	tokenString, err = other.SignedString([]byte(secretA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = manager.Parse(tokenString, &jwt.StandardClaims{}); err == nil {
+		t.Error("HS512 token verified with an HS256 key")
+	}
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, &jwt.StandardClaims{Subject: "admin"})
+	none.Header["kid"] = "h"
+	tokenString, err = none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = manager.Parse(tokenString, &jwt.StandardClaims{}); err == nil {
+		t.Error("unsigned token verified")
+	}
+}
+
+// Rotating the active key keeps the tokens of the previous one valid until it is dropped
+func TestRotation(t *testing.T) {
+	before := newManager(t, KeysConfig{Active: "a", Keys: []KeyConfig{hmacKey("a", secretA)}})
+	oldToken := sign(t, before)
+
+	rotated := newManager(t, KeysConfig{Active: "b", Keys: []KeyConfig{hmacKey("a", secretA), hmacKey("b", secretB)}})
+	if _, err := rotated.Parse(oldToken, &jwt.StandardClaims{}); err != nil {
+		t.Errorf("token of the previous key rejected after rotation: %v", err)
+	}
+	newToken := sign(t, rotated)
+	token, err := rotated.Parse(newToken, &jwt.StandardClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := token.Header["kid"]; kid != "b" {
+		t.Errorf("kid after rotation = %v, want b", kid)
+	}
+	if _, err = before.Parse(newToken, &jwt.StandardClaims{}); err == nil {
+		t.Error("token of the new key verified by a manager that does not know it")
+	}
+
+	retired := newManager(t, KeysConfig{Active: "b", Keys: []KeyConfig{hmacKey("b", secretB)}})
+	if _, err = retired.Parse(oldToken, &jwt.StandardClaims{}); err == nil {
+		t.Error("token of a retired key still verifies")
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	configs := map[string]KeysConfig{
+		"missing kid":    {Active: "", Keys: []KeyConfig{hmacKey("", secretA)}},
+		"duplicate kid":  {Active: "a", Keys: []KeyConfig{hmacKey("a", secretA), hmacKey("a", secretB)}},
+		"short secret":   {Active: "a", Keys: []KeyConfig{hmacKey("a", "short")}},
+		"unknown alg":    {Active: "a", Keys: []KeyConfig{{ID: "a", Algorithm: "none", Secret: secretA}}},
+		"missing active": {Active: "b", Keys: []KeyConfig{hmacKey("a", secretA)}},
+	}
+	for name, config := range configs {
+		if _, err := NewKeyManager(config); err == nil {
+			t.Errorf("%s: config accepted", name)
+		}
+	}
+}